@@ -0,0 +1,12 @@
+//go:build !sqlcipher
+
+package main
+
+// Storage drivers are registered for side effects; import the ones this
+// binary should support. The default build supports plain sqlite3 and
+// postgres. Build with `-tags sqlcipher` to swap sqlite3 for an
+// encrypted-at-rest equivalent (see sqlcipher.go).
+import (
+	_ "code.dogecoin.org/dkm/internal/store/postgres"
+	_ "code.dogecoin.org/dkm/internal/store/sqlite"
+)
@@ -0,0 +1,10 @@
+//go:build sqlcipher
+
+package main
+
+// Registering the sqlcipher driver requires cgo and the SQLCipher amalgamation,
+// so it's only pulled in by binaries built with `-tags sqlcipher`.
+import (
+	_ "code.dogecoin.org/dkm/internal/store/postgres"
+	_ "code.dogecoin.org/dkm/internal/store/sqlcipher"
+)
@@ -0,0 +1,156 @@
+// Package jwt implements the minimal subset of JSON Web Tokens and JSON Web
+// Key Sets that DKM needs to issue and verify its own session tokens: compact
+// serialization, EdDSA (Ed25519) signing, and a single active signing key. It
+// does not implement algorithm negotiation, multi-key JWKS, or any JWT
+// feature DKM itself doesn't use.
+package jwt
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrMalformed = errors.New("jwt: malformed token")
+var ErrBadSignature = errors.New("jwt: bad signature")
+var ErrExpired = errors.New("jwt: token expired")
+
+// Claims are the claims DKM puts in a session token: who it's for (Sub, a
+// key name), when it was issued and expires, a unique id (Jti, used for
+// server-side revocation since the token itself is otherwise stateless),
+// and the capabilities it grants (Scope).
+type Claims struct {
+	Sub   string   `json:"sub"`
+	Iat   int64    `json:"iat"`
+	Exp   int64    `json:"exp"`
+	Jti   string   `json:"jti"`
+	Scope []string `json:"scope,omitempty"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// Sign encodes and signs `claims` as a compact JWT (header.payload.signature)
+// using EdDSA (Ed25519) under `priv`, identified in the header by `kid`.
+func Sign(priv ed25519.PrivateKey, kid string, claims Claims) (string, error) {
+	hdr, err := json.Marshal(header{Alg: "EdDSA", Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := b64(hdr) + "." + b64(body)
+	sig := ed25519.Sign(priv, []byte(signingInput))
+	return signingInput + "." + b64(sig), nil
+}
+
+// Verify parses `token` and checks its signature against `pub` and its `exp`
+// claim against the current time, returning its claims either way (so a
+// caller can still inspect an expired token's Sub/Jti if it wants to).
+func Verify(pub ed25519.PublicKey, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrMalformed
+	}
+	hdr, err := unb64(parts[0])
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	body, err := unb64(parts[1])
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	sig, err := unb64(parts[2])
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	var h header
+	if err := json.Unmarshal(hdr, &h); err != nil {
+		return Claims{}, ErrMalformed
+	}
+	if h.Alg != "EdDSA" {
+		return Claims{}, ErrMalformed
+	}
+	if !ed25519.Verify(pub, []byte(parts[0]+"."+parts[1]), sig) {
+		return Claims{}, ErrBadSignature
+	}
+	var claims Claims
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return Claims{}, ErrMalformed
+	}
+	if time.Now().Unix() >= claims.Exp {
+		return claims, ErrExpired
+	}
+	return claims, nil
+}
+
+// HasScope reports whether `granted` includes `want`.
+func HasScope(granted []string, want string) bool {
+	for _, s := range granted {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func unb64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// JWK is one entry of a JWK Set, in the form described by RFC 7517 for an
+// OKP (Ed25519) public key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+// JWKSet is a JWK Set as served by web.WebAPI's /jwks.json endpoint.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKSFor builds the JWK Set representation of `pub`.
+func JWKSFor(pub ed25519.PublicKey, kid string) JWKSet {
+	return JWKSet{Keys: []JWK{{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   b64(pub),
+		Kid: kid,
+		Use: "sig",
+		Alg: "EdDSA",
+	}}}
+}
+
+type scopeKey struct{}
+
+// WithScope returns a context carrying the scope granted by the bearer token
+// presented with a request, read back with ScopeFromContext. This mirrors
+// audit.WithCaller/CallerFromContext: per-request identity threaded through
+// ctx at the web layer, not down into StoreCtx.
+func WithScope(ctx context.Context, scope []string) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// ScopeFromContext returns the scope stored by WithScope, or nil if none was set.
+func ScopeFromContext(ctx context.Context) []string {
+	scope, _ := ctx.Value(scopeKey{}).([]string)
+	return scope
+}
@@ -0,0 +1,43 @@
+package jwt
+
+import (
+	"context"
+	"testing"
+)
+
+// TestHasScopeFailsClosed guards the exact bug the chunk1-2/chunk1-3 review
+// caught: callers in internal/keymgr build their scope checks as
+// `!HasScope(ScopeFromContext(ctx), required)`, relying on HasScope to deny
+// a nil or empty scope list rather than treat it as "no restriction". A
+// request with no Authorization header (so no scope attached at all) must
+// be denied exactly like one with an insufficient scope, not let through.
+func TestHasScopeFailsClosed(t *testing.T) {
+	const required = "delegate:read"
+	cases := []struct {
+		name   string
+		scope  []string
+		expect bool
+	}{
+		{"nil scope", nil, false},
+		{"empty scope", []string{}, false},
+		{"unrelated scope", []string{"delegate:create"}, false},
+		{"required scope granted", []string{"delegate:create", required}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := HasScope(c.scope, required); got != c.expect {
+				t.Errorf("HasScope(%v, %q) = %v, want %v", c.scope, required, got, c.expect)
+			}
+		})
+	}
+}
+
+// TestScopeFromContextDefaultsToNil documents that a context nobody called
+// WithScope on (e.g. a request whose Authorization header was omitted)
+// carries no scope at all, rather than an implicit all-access scope -- the
+// condition callers must fail closed on.
+func TestScopeFromContextDefaultsToNil(t *testing.T) {
+	if scope := ScopeFromContext(context.Background()); scope != nil {
+		t.Errorf("ScopeFromContext(background) = %v, want nil", scope)
+	}
+}
@@ -1,14 +1,71 @@
 package internal
 
+import (
+	"context"
+	"crypto/ed25519"
+
+	"code.dogecoin.org/dkm/internal/jwt"
+)
+
 type KeyMgr interface {
 	CreateKey(pass string) (mnemonic []string, err error)
-	LogIn(pass string) (token string, ends int, err error)
+	// LogIn issues a session token scoped to `scope` (or keymgr.DefaultScope,
+	// if empty).
+	LogIn(ctx context.Context, pass string, scope []string) (token string, ends int, err error)
 	RollToken(token string) (newtoken string, ends int, err error)
-	LogOut(token string)
-	ChangePassword(password string, newpass string) error
-	RecoverPassword(mnemonic []string, newpass string) error
-	CreateDelegate(id string, pass string) (token string, pub []byte, err error)
+	LogOut(ctx context.Context, token string)
+	ChangePassword(ctx context.Context, password string, newpass string) error
+	RecoverPassword(ctx context.Context, mnemonic []string, newpass string) error
+	CreateDelegate(ctx context.Context, id string, pass string) (token string, pub []byte, err error)
 	GetDelegatePub(id string) (pubkey []byte, err error)
-	GetDelegatePriv(id string, token string) (privkey []byte, pubkey []byte, err error)
-	MakeDelegate(id string, token string) (privkey []byte, pubkey []byte, wif string, err error)
+	GetDelegatePriv(ctx context.Context, id string, token string) (privkey []byte, pubkey []byte, err error)
+	MakeDelegate(ctx context.Context, id string, token string) (privkey []byte, pubkey []byte, wif string, err error)
+
+	// KeyRing: multiple named master keys under one daemon, e.g. separate
+	// hot/cold or per-tenant keys.
+	CreateNamedKey(name string, pass string) (mnemonic []string, err error)
+	ListKeys() (names []string, err error)
+	DeleteKey(name string, pass string) error
+	LogInTo(ctx context.Context, name string, pass string, scope []string) (token string, ends int, err error)
+	// CreateDelegateFor is CreateDelegate against a named key rather than the
+	// default ("main") key.
+	CreateDelegateFor(ctx context.Context, name string, id string, pass string) (token string, pub []byte, err error)
+
+	// External delegates never have a private key loaded into this process:
+	// the pubkey and an opaque signer endpoint (e.g. a hardware wallet or
+	// remote HSM) are recorded instead, and SignWithDelegate forwards signing
+	// requests to it.
+	CreateExternalDelegate(ctx context.Context, id string, pubkey []byte, signerURL string) (token string, err error)
+	// CreateExternalDelegateFor is CreateExternalDelegate against a named key
+	// rather than the default ("main") key.
+	CreateExternalDelegateFor(ctx context.Context, name string, id string, pubkey []byte, signerURL string) (token string, err error)
+	SignWithDelegate(id string, token string, sighash []byte) (sig []byte, err error)
+
+	// SignDelegate signs an arbitrary payload with a delegate key (local or
+	// external) without the private key ever leaving DKM, e.g. for ACME
+	// DNS-01 key authorizations or RPC request signing. hash selects how
+	// payload is digested before signing: HashSHA256 or HashDogecoinMessage.
+	// Returns the compact-encoded signature and the delegate's public key.
+	SignDelegate(id string, token string, payload []byte, hash string) (sig []byte, pub []byte, err error)
+	// VerifyDelegate checks sig (as returned by SignDelegate) against payload
+	// and the delegate's recorded public key, using the same hash algorithm.
+	VerifyDelegate(id string, payload []byte, sig []byte, hash string) (ok bool, err error)
+
+	// AttestDelegate issues a short-lived, JWT-shaped attestation token
+	// proving control of delegate id's key, for a relying party (another pup,
+	// or an external service) to verify offline against
+	// /delegate/.well-known/jwks.json. claims are merged into the token body
+	// over the standard iss/sub/iat claims DKM always sets.
+	AttestDelegate(id string, token string, claims map[string]any) (attestation string, err error)
+
+	// VerifyToken checks a session token's signature, expiry and revocation
+	// status, returning its claims if it's still valid.
+	VerifyToken(token string) (claims jwt.Claims, err error)
+	// SigningPublicKey returns the public half of the key sessions tokens are
+	// signed with, and its `kid`, for serving at /jwks.json.
+	SigningPublicKey() (pub ed25519.PublicKey, kid string, err error)
+
+	// ListSessions returns metadata (but never key material) for every
+	// session that hasn't yet expired, for the /sessions admin endpoint.
+	ListSessions() (sessions []Session, err error)
 }
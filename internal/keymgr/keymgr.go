@@ -2,13 +2,25 @@ package keymgr
 
 import (
 	"bytes"
+	"context"
+	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"code.dogecoin.org/dkm/internal"
+	"code.dogecoin.org/dkm/internal/audit"
+	"code.dogecoin.org/dkm/internal/jwt"
+	"code.dogecoin.org/dkm/internal/keymgr/signer"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
 	"github.com/dogeorg/doge"
 	"github.com/dogeorg/doge/bip39"
 	"golang.org/x/crypto/argon2"
@@ -19,9 +31,38 @@ var _ internal.KeyMgr = &keyMgr{}
 
 const SessionTime = 10 * 60 // seconds
 const HandoverTime = 10     // seconds
-const MainKey = 1           // ID of main key
+const MainKeyName = "main"  // name of the default key, used by the single-key API
 const MnemonicEntropyBits = 256
 
+// Delegate kinds: "local" delegates are BIP32 child keys derived from the
+// parent key and encrypted at rest; "remote" (external) delegates hold no
+// private key material here at all — signing is forwarded to an external
+// signer (a hardware wallet daemon, a remote HSM, ...) via the signer package.
+const DelegateKindLocal = "local"
+const DelegateKindRemote = "remote"
+
+// externalDelegateCanary is encrypted under the delegate's token and stored
+// alongside an external delegate's pubkey/signer URL, purely so
+// SignWithDelegate can verify the caller's token before forwarding a signing
+// request — an external delegate has no real key material to protect.
+var externalDelegateCanary = []byte("dkm-external-delegate-canary-v1")
+
+// Hash algorithms supported by SignDelegate/VerifyDelegate. HashDogecoinMessage
+// reproduces Dogecoin Core's "Signed Message" format, so a delegate signature
+// verifies the same way a wallet's `signmessage`/`verifymessage` would.
+const HashSHA256 = "sha256"
+const HashDogecoinMessage = "dogecoin-message"
+
+const dogecoinMessagePrefix = "Dogecoin Signed Message:\n"
+
+// Session token scopes. ScopeDelegateCreate gates CreateDelegate/CreateDelegateFor;
+// ScopeDelegateRead gates GetDelegatePriv. DefaultScope is granted when LogIn/LogInTo
+// is called without an explicit scope, preserving today's unrestricted behaviour.
+const ScopeDelegateCreate = "delegate:create"
+const ScopeDelegateRead = "delegate:read"
+
+var DefaultScope = []string{ScopeDelegateCreate, ScopeDelegateRead}
+
 // Argon2 parameters.
 // RFC 9106: "If much less memory is available, a uniformly safe option is Argon2id
 // with t=3 iterations, p=4 lanes, m=2^(16) (64 MiB of RAM), 128-bit salt, and 256-bit
@@ -31,6 +72,92 @@ const ArgonThreads = 4
 const ArgonMemory = 64 * 1024 // 64 MB
 const SecretKeySize = doge.SerializedBip32KeyLength
 
+const KDFAlgoArgon2id = "argon2id"
+const DefaultSaltLen = 16
+
+// KDFParams describes the Argon2id cost parameters used to derive the
+// password key for one encrypted record. Stored alongside the record (in its
+// `kdf` column) rather than hardcoded, so the cost can be raised over time
+// without invalidating already-encrypted keys: decryptKey reads the params
+// that were actually used for that record, and LogIn/GetDelegatePriv
+// transparently re-encrypt records found to be using weaker-than-current
+// parameters.
+type KDFParams struct {
+	Algo    string `json:"algo"`
+	Time    uint32 `json:"t"`
+	Memory  uint32 `json:"m"`
+	Threads uint8  `json:"p"`
+	SaltLen uint8  `json:"salt_len"`
+}
+
+// legacyKDFParams describes the fixed parameters used by every record created
+// before the `kdf` column existed (i.e. records with a NULL/empty kdf).
+var legacyKDFParams = KDFParams{Algo: KDFAlgoArgon2id, Time: ArgonTime, Memory: ArgonMemory, Threads: ArgonThreads, SaltLen: DefaultSaltLen}
+
+var defaultKDFParamsMu sync.Mutex
+var defaultKDFParams = legacyKDFParams
+
+// SetDefaultKDFParams changes the Argon2id parameters used to encrypt keys
+// from now on, e.g. to raise the memory/time cost on newer hardware without
+// recompiling. Existing records keep using their own stored parameters until
+// they are next unlocked, at which point they are transparently rehashed.
+func SetDefaultKDFParams(p KDFParams) {
+	defaultKDFParamsMu.Lock()
+	defer defaultKDFParamsMu.Unlock()
+	defaultKDFParams = p
+}
+
+func getDefaultKDFParams() KDFParams {
+	defaultKDFParamsMu.Lock()
+	defer defaultKDFParamsMu.Unlock()
+	return defaultKDFParams
+}
+
+// weaker reports whether `p` costs less to compute than `want`, i.e. whether
+// a record using `p` should be rehashed to `want`.
+func (p KDFParams) weaker(want KDFParams) bool {
+	return p.Time < want.Time || p.Memory < want.Memory || p.Threads < want.Threads
+}
+
+// encodeKDFParams serializes `p` for storage in a record's `kdf` column.
+func encodeKDFParams(p KDFParams) []byte {
+	b, err := json.Marshal(p)
+	if err != nil {
+		panic(err) // KDFParams only has marshalable fields; cannot fail
+	}
+	return b
+}
+
+// decodeKDFParams reads a record's `kdf` column, falling back to
+// legacyKDFParams for records stored before the column existed.
+func decodeKDFParams(raw []byte) KDFParams {
+	if len(raw) == 0 {
+		return legacyKDFParams
+	}
+	var p KDFParams
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return legacyKDFParams
+	}
+	return p
+}
+
+// CalibrateKDFParams picks an Argon2id `Time` cost that takes approximately
+// `target` to compute at the given memory/thread cost, for use during
+// first-time setup on unknown hardware. Intended to be passed to
+// SetDefaultKDFParams before the first key is created.
+func CalibrateKDFParams(target time.Duration, memory uint32, threads uint8) KDFParams {
+	p := KDFParams{Algo: KDFAlgoArgon2id, Time: 1, Memory: memory, Threads: threads, SaltLen: DefaultSaltLen}
+	salt := make([]byte, p.SaltLen)
+	for {
+		start := time.Now()
+		argon2.IDKey([]byte("calibrate"), salt, p.Time, p.Memory, p.Threads, chacha20poly1305.KeySize)
+		if time.Since(start) >= target || p.Time >= 100 {
+			return p
+		}
+		p.Time++
+	}
+}
+
 var ErrOutOfEntropy = errors.New("insufficient entropy available")
 var ErrWrongPassword = errors.New("incorrect password")
 var ErrBadToken = errors.New("invalid or expired token")
@@ -40,31 +167,102 @@ var ErrWrongMnemonic = errors.New("mnemonic does not match existing key")
 var ErrNoKey = errors.New("key has not been created")
 var ErrWrongToken = errors.New("invalid token")
 var ErrBadKey = errors.New("bad stored key: cannot decode key")
+var ErrExternalDelegate = errors.New("delegate has no local private key: use SignWithDelegate")
+var ErrForbiddenScope = errors.New("session token does not grant the required scope")
 
 type keyMgr struct {
-	store    internal.StoreCtx
+	store internal.StoreCtx
+	// sessions is keyed by the session JWT's `jti` claim, not the token
+	// itself, since the token is otherwise a stateless, independently
+	// verifiable JWT signed with signingKey.
 	sessions map[string]session
-	key      []byte
+	// keys/keyIDs hold the decrypted master key (and its config.id) for every
+	// name that has at least one live session; cleaned up as sessions expire.
+	keys   map[string][]byte
+	keyIDs map[string]int
+
+	// sessionTTL overrides SessionTime (the lifetime of a freshly issued
+	// session, in seconds), set from --session-ttl in main.go.
+	sessionTTL int
+
+	// signingKey/signingKid cache the server's JWT signing key, loaded (and
+	// created, on first use) from the store on first access.
+	signingKeyMu sync.Mutex
+	signingKey   ed25519.PrivateKey
+	signingKid   string
 }
 
 type session struct {
+	keyName string
+	scope   []string
+	issued  time.Time
 	expires time.Time
 	rolled  bool
 }
 
-func New(store internal.StoreCtx) internal.KeyMgr {
-	return &keyMgr{
-		store:    store,
-		sessions: make(map[string]session),
+// Options configures New. The zero value is today's behaviour: the default
+// session lifetime, and whatever sessions the store already has on record.
+type Options struct {
+	// SessionTTL overrides SessionTime (the lifetime of a freshly issued
+	// session, in seconds). Zero means use SessionTime.
+	SessionTTL int
+}
+
+// New constructs a keyMgr backed by `store`. It reloads every unexpired
+// session row `store` already has on record (see SessionStore), so clients
+// holding a token issued before a DKM restart don't need to log in again
+// for session bookkeeping (RollToken, LogOut) to keep working. It does NOT
+// recover the decrypted master key a session grants: that is never
+// persisted, so minting new delegates from a reloaded session still
+// requires a fresh login (or an auto-unsealed one, see --unseal-from-env
+// in main.go) until the process's own login populates `keys` again.
+func New(store internal.StoreCtx, opts Options) internal.KeyMgr {
+	ttl := opts.SessionTTL
+	if ttl <= 0 {
+		ttl = SessionTime
+	}
+	km := &keyMgr{
+		store:      store,
+		sessions:   make(map[string]session),
+		keys:       make(map[string][]byte),
+		keyIDs:     make(map[string]int),
+		sessionTTL: ttl,
+	}
+	km.loadSessions()
+	return km
+}
+
+// loadSessions populates km.sessions from every unexpired row in the store,
+// called once at startup.
+func (km *keyMgr) loadSessions() {
+	rows, err := km.store.ListSessions()
+	if err != nil {
+		log.Printf("loadSessions: failed to list sessions: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, row := range rows {
+		if row.Expires.After(now) {
+			km.sessions[row.Jti] = session{keyName: row.KeyName, scope: row.Scope, issued: row.Issued, expires: row.Expires}
+		}
 	}
 }
 
 func (km *keyMgr) CreateKey(pass string) (mnemonic []string, err error) {
+	return km.CreateNamedKey(MainKeyName, pass)
+}
+
+func (km *keyMgr) CreateNamedKey(name string, pass string) (mnemonic []string, err error) {
 	mnemonic, key, pub, err := km.generateMnemonic()
 	if err != nil {
 		return nil, err
 	}
-	err = km.encryptAndSetKey(MainKey, key, pub, pass, false)
+	id, err := km.store.NextKeyID()
+	if err != nil {
+		memZero(key)
+		return nil, err
+	}
+	err = km.encryptAndSetKey(km.store, id, name, key, pub, pass, false)
 	memZero(key)
 	if err != nil {
 		if internal.IsAlreadyExistsError(err) {
@@ -75,74 +273,189 @@ func (km *keyMgr) CreateKey(pass string) (mnemonic []string, err error) {
 	return mnemonic, nil
 }
 
-func (km *keyMgr) LogIn(pass string) (token string, ends int, err error) {
+func (km *keyMgr) ListKeys() (names []string, err error) {
+	return km.store.ListKeys()
+}
+
+func (km *keyMgr) ListSessions() (sessions []internal.Session, err error) {
+	all, err := km.store.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, s := range all {
+		if s.Expires.After(now) {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, nil
+}
+
+func (km *keyMgr) DeleteKey(name string, pass string) error {
+	id, key, _, err := km.getAndDecryptKeyByName(km.store, name, pass)
+	memZero(key)
+	if err != nil {
+		return err
+	}
+	err = km.store.DeleteKey(id)
+	if err != nil {
+		return err
+	}
+	// drop any live sessions for this key; they no longer unlock anything.
+	for token, s := range km.sessions {
+		if s.keyName == name {
+			delete(km.sessions, token)
+		}
+	}
+	km.forgetKey(name)
+	return nil
+}
+
+func (km *keyMgr) LogIn(ctx context.Context, pass string, scope []string) (token string, ends int, err error) {
+	return km.LogInTo(ctx, MainKeyName, pass, scope)
+}
+
+func (km *keyMgr) LogInTo(ctx context.Context, name string, pass string, scope []string) (token string, ends int, err error) {
 	km.cleanSessions()
-	// verify the password
-	key, _, err := km.getAndDecryptKey(MainKey, pass)
+	if len(scope) == 0 {
+		scope = DefaultScope
+	}
+	caller := audit.CallerFromContext(ctx)
+	// verify the password, rehashing in the same transaction if the stored
+	// KDF parameters are weaker than the current defaults. The audit event
+	// is appended (and committed) in the same transaction regardless of
+	// outcome, so a wrong-password attempt is recorded even though the rest
+	// of the login fails.
+	var id int
+	var key []byte
+	var opErr error
+	err = km.store.Transaction(func(tx internal.StoreTxn) error {
+		id, key, _, opErr = km.getAndDecryptKeyByName(tx, name, pass)
+		if internal.IsDBConflictError(opErr) {
+			return opErr // retry the whole attempt; nothing to audit yet
+		}
+		if aerr := tx.AppendAudit(audit.Event{Op: "LogIn", KeyID: id, Caller: caller, Outcome: loginOutcome(opErr)}); aerr != nil {
+			return aerr
+		}
+		return nil
+	})
 	if err != nil {
 		memZero(key)
-		if errors.Is(err, internal.ErrNotFound) {
-			return "", 0, ErrNoKey
-		}
-		return // wrong password
+		return "", 0, err // failed to append the audit event itself
+	}
+	if opErr != nil {
+		memZero(key)
+		return "", 0, opErr // ErrNoKey, or wrong password
 	}
-	token, ends, err = km.newSession()
+	token, ends, err = km.newSession(name, scope)
 	if err != nil {
 		memZero(key)
 		return // out of entropy
 	}
-	km.key = key // keep key in-memory for `MakeDelegate`
+	km.keys[name] = key // keep key in-memory for `MakeDelegate`
+	km.keyIDs[name] = id
 	return token, ends, nil
 }
 
+// loginOutcome summarizes a login attempt's result for the audit log.
+func loginOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrNoKey):
+		return "no-key"
+	case errors.Is(err, ErrWrongPassword):
+		return "wrong-password"
+	default:
+		return "error"
+	}
+}
+
 func (km *keyMgr) RollToken(token string) (newtoken string, ends int, err error) {
 	km.cleanSessions()
+	claims, verr := km.VerifyToken(token)
+	if verr != nil {
+		return "", 0, ErrBadToken
+	}
 	now := time.Now()
-	if s, ok := km.sessions[token]; ok && !s.rolled && s.expires.After(now) {
+	if s, ok := km.sessions[claims.Jti]; ok && !s.rolled && s.expires.After(now) {
 		// keep the current token alive for a short handover time,
 		// in case there are concurrent requests using the old token.
-		km.sessions[token] = session{expires: time.Now().Add(HandoverTime * time.Second), rolled: true}
+		handoverExp := now.Add(HandoverTime * time.Second)
+		km.sessions[claims.Jti] = session{keyName: s.keyName, scope: s.scope, issued: s.issued, expires: handoverExp, rolled: true}
+		if perr := km.store.PutSession(internal.Session{Jti: claims.Jti, KeyName: s.keyName, Scope: s.scope, Issued: s.issued, Expires: handoverExp, LastUsed: now}); perr != nil {
+			log.Printf("RollToken: failed to persist handover session: %v", perr)
+		}
 		// issue a new token.
-		return km.newSession()
+		return km.newSession(s.keyName, s.scope)
 	} else {
 		// the token has already expired.
-		delete(km.sessions, token)
+		delete(km.sessions, claims.Jti)
 		return "", 0, ErrBadToken
 	}
 }
 
-func (km *keyMgr) LogOut(token string) {
+func (km *keyMgr) LogOut(ctx context.Context, token string) {
+	caller := audit.CallerFromContext(ctx)
+	claims, _ := km.VerifyToken(token)
+	keyName := ""
+	if s, ok := km.sessions[claims.Jti]; ok {
+		keyName = s.keyName
+	}
 	// invalidate the token if it exists.
-	delete(km.sessions, token)
-	// remove key from memory after all sessions expire.
+	delete(km.sessions, claims.Jti)
+	if claims.Jti != "" {
+		// revoke the jti too, so it can't be replayed before its own exp.
+		if rerr := km.store.RevokeJTI(claims.Jti, time.Unix(claims.Exp, 0)); rerr != nil {
+			log.Printf("LogOut: failed to revoke token: %v", rerr)
+		}
+		if derr := km.store.DeleteSession(claims.Jti); derr != nil {
+			log.Printf("LogOut: failed to delete persisted session: %v", derr)
+		}
+	}
+	// remove keys from memory after all of their sessions expire.
 	km.cleanSessions()
-	if len(km.sessions) < 1 {
-		memZero(km.key)
-		km.key = nil
+	if aerr := km.store.AppendAudit(audit.Event{Op: "LogOut", KeyID: km.keyIDs[keyName], Caller: caller, Outcome: "ok"}); aerr != nil {
+		log.Printf("LogOut: failed to append audit event: %v", aerr)
 	}
 }
 
-func (km *keyMgr) ChangePassword(password string, newpass string) error {
-	// decrypt the key using the current password
-	key, pub, err := km.getAndDecryptKey(MainKey, password)
-	if err != nil {
-		memZero(key)
-		if errors.Is(err, internal.ErrNotFound) {
-			return ErrNoKey
+func (km *keyMgr) ChangePassword(ctx context.Context, password string, newpass string) error {
+	caller := audit.CallerFromContext(ctx)
+	var id int
+	var key, pub []byte
+	var opErr error
+	err := km.store.Transaction(func(tx internal.StoreTxn) error {
+		// decrypt the key using the current password
+		id, key, pub, opErr = km.getAndDecryptKeyByName(tx, MainKeyName, password)
+		if opErr == nil {
+			opErr = km.encryptAndSetKey(tx, id, MainKeyName, key, pub, newpass, true)
 		}
-		return err
-	}
-	err = km.encryptAndSetKey(MainKey, key, pub, newpass, true)
+		if internal.IsDBConflictError(opErr) {
+			return opErr // retry the whole attempt; nothing to audit yet
+		}
+		if aerr := tx.AppendAudit(audit.Event{Op: "ChangePassword", KeyID: id, Caller: caller, Outcome: loginOutcome(opErr)}); aerr != nil {
+			return aerr
+		}
+		return nil
+	})
 	memZero(key)
-	return err
+	if err != nil {
+		return err // failed to append the audit event itself
+	}
+	return opErr
 }
 
-func (km *keyMgr) RecoverPassword(mnemonic []string, newpass string) error {
+func (km *keyMgr) RecoverPassword(ctx context.Context, mnemonic []string, newpass string) error {
+	caller := audit.CallerFromContext(ctx)
 	// get the existing stored pubkey
-	pub, err := km.store.GetKeyPub(MainKey) // ErrNotFound|error
+	id, _, _, _, pub, _, err := km.store.GetKeyByName(MainKeyName)
 	if err != nil {
 		if errors.Is(err, internal.ErrNotFound) {
-			return ErrNoKey
+			err = ErrNoKey
+		}
+		if aerr := km.store.AppendAudit(audit.Event{Op: "RecoverPassword", Caller: caller, Outcome: loginOutcome(err)}); aerr != nil {
+			log.Printf("RecoverPassword: failed to append audit event: %v", aerr)
 		}
 		return err
 	}
@@ -157,25 +470,55 @@ func (km *keyMgr) RecoverPassword(mnemonic []string, newpass string) error {
 	// generate Bip32 master key from seed
 	master, err := doge.Bip32MasterFromSeed(seed, &doge.DogeMainNetChain) // ErrBadSeed,ErrAnotherSeed
 	if err != nil {
-		return ErrWrongMnemonic // we check validity when we generate the mnemonic
+		err = ErrWrongMnemonic // we check validity when we generate the mnemonic
+	} else {
+		defer master.Clear() // clear key material
+		newpub := master.GetECPubKey()
+		if !bytes.Equal(pub, newpub[:]) {
+			err = ErrWrongMnemonic // mnemonic pubkey differs from the stored pubkey
+		}
 	}
-	defer master.Clear() // clear key material
-
-	newpub := master.GetECPubKey()
-	if !bytes.Equal(pub, newpub[:]) {
-		return ErrWrongMnemonic // mnemonic pubkey differs from the stored pubkey
+	if err != nil {
+		if aerr := km.store.AppendAudit(audit.Event{Op: "RecoverPassword", KeyID: id, Caller: caller, Outcome: "wrong-mnemonic"}); aerr != nil {
+			log.Printf("RecoverPassword: failed to append audit event: %v", aerr)
+		}
+		return err
 	}
 
 	// re-encrypt the stored key using the new password
 	key := []byte(master.EncodeWIF())
 	defer memZero(key)
-	err = km.encryptAndSetKey(MainKey, key, pub, newpass, true)
-	return err
+	var opErr error
+	err = km.store.Transaction(func(tx internal.StoreTxn) error {
+		opErr = km.encryptAndSetKey(tx, id, MainKeyName, key, pub, newpass, true)
+		if internal.IsDBConflictError(opErr) {
+			return opErr // retry the whole attempt; nothing to audit yet
+		}
+		return tx.AppendAudit(audit.Event{Op: "RecoverPassword", KeyID: id, Caller: caller, Outcome: loginOutcome(opErr)})
+	})
+	if err != nil {
+		return err // failed to append the audit event itself
+	}
+	return opErr
+}
+
+func (km *keyMgr) CreateDelegate(ctx context.Context, id string, pass string) (tok string, pubkey []byte, e error) {
+	return km.CreateDelegateFor(ctx, MainKeyName, id, pass)
 }
 
-func (km *keyMgr) CreateDelegate(id string, pass string) (tok string, pubkey []byte, e error) {
-	key, _, err := km.getAndDecryptKey(MainKey, pass) // ErrNoKey
+func (km *keyMgr) CreateDelegateFor(ctx context.Context, name string, id string, pass string) (tok string, pubkey []byte, e error) {
+	caller := audit.CallerFromContext(ctx)
+	if !jwt.HasScope(jwt.ScopeFromContext(ctx), ScopeDelegateCreate) {
+		if aerr := km.store.AppendAudit(audit.Event{Op: "CreateDelegate", DelegateID: id, Caller: caller, Outcome: "forbidden"}); aerr != nil {
+			log.Printf("CreateDelegateFor: failed to append audit event: %v", aerr)
+		}
+		return "", nil, ErrForbiddenScope
+	}
+	parentKey, key, _, err := km.getAndDecryptKeyByName(km.store, name, pass) // ErrNoKey
 	if err != nil {
+		if aerr := km.store.AppendAudit(audit.Event{Op: "CreateDelegate", DelegateID: id, Caller: caller, Outcome: loginOutcome(err)}); aerr != nil {
+			log.Printf("CreateDelegateFor: failed to append audit event: %v", aerr)
+		}
 		return "", nil, err
 	}
 	master, err := doge.DecodeBip32WIF(string(key), &doge.DogeMainNetChain) // bad-key
@@ -192,8 +535,9 @@ func (km *keyMgr) CreateDelegate(id string, pass string) (tok string, pubkey []b
 		return "", nil, ErrBadKey
 	}
 	defer pupKey.Clear() // clear key material at exit
+	var opErr error
 	err = km.store.Transaction(func(tx internal.StoreTxn) error {
-		max, err := tx.GetMaxDelegate()
+		max, err := tx.GetMaxDelegate(parentKey)
 		if err != nil {
 			return err
 		}
@@ -209,15 +553,22 @@ func (km *keyMgr) CreateDelegate(id string, pass string) (tok string, pubkey []b
 			return err
 		}
 		child_wif := []byte(child.EncodeWIF())
-		defer memZero(child_wif)                                 // clear key material at exit
-		salt, nonce, enc, err := km.encryptKey(child_wif, token) // ErrOutOfEntropy
+		defer memZero(child_wif)                                      // clear key material at exit
+		salt, nonce, enc, kdf, err := km.encryptKey(child_wif, token) // ErrOutOfEntropy
 		if err != nil {
 			return err
 		}
 		pub := child.GetECPubKey()
-		err = tx.SetDelegate(id, salt, nonce, enc, pub[:], keyIndex) // DBConflict|AlreadyExists|error
-		if err != nil {
-			return err
+		setErr := tx.SetDelegate(id, parentKey, salt, nonce, enc, pub[:], kdf, keyIndex, DelegateKindLocal, "", false) // DBConflict|AlreadyExists|error
+		if internal.IsDBConflictError(setErr) {
+			return setErr // retry the whole attempt; nothing to audit yet
+		}
+		if aerr := tx.AppendAudit(audit.Event{Op: "CreateDelegate", KeyID: parentKey, DelegateID: id, Caller: caller, Outcome: loginOutcome(setErr)}); aerr != nil {
+			return aerr
+		}
+		if setErr != nil {
+			opErr = setErr
+			return nil // commit, so the failed attempt is still recorded
 		}
 		tok = token     // set return value
 		pubkey = pub[:] // set return value
@@ -226,13 +577,258 @@ func (km *keyMgr) CreateDelegate(id string, pass string) (tok string, pubkey []b
 	if err != nil {
 		return "", nil, err
 	}
+	if opErr != nil {
+		return "", nil, opErr
+	}
 	return
 }
 
-func (km *keyMgr) MakeDelegate(id string, token string) (privkey []byte, pubkey []byte, wif string, e error) {
+func (km *keyMgr) CreateExternalDelegate(ctx context.Context, id string, pubkey []byte, signerURL string) (tok string, err error) {
+	return km.CreateExternalDelegateFor(ctx, MainKeyName, id, pubkey, signerURL)
+}
+
+func (km *keyMgr) CreateExternalDelegateFor(ctx context.Context, name string, id string, pubkey []byte, signerURL string) (tok string, err error) {
+	caller := audit.CallerFromContext(ctx)
+	if !jwt.HasScope(jwt.ScopeFromContext(ctx), ScopeDelegateCreate) {
+		if aerr := km.store.AppendAudit(audit.Event{Op: "CreateExternalDelegate", DelegateID: id, Caller: caller, Outcome: "forbidden"}); aerr != nil {
+			log.Printf("CreateExternalDelegateFor: failed to append audit event: %v", aerr)
+		}
+		return "", ErrForbiddenScope
+	}
+	parentKey, _, _, _, _, _, err := km.store.GetKeyByName(name) // ErrNotFound|error
+	if err != nil {
+		if errors.Is(err, internal.ErrNotFound) {
+			return "", ErrNoKey
+		}
+		return "", err
+	}
+	if _, err := signer.Dial(signerURL); err != nil {
+		return "", err
+	}
+	token, err := generateToken() // ErrOutOfEntropy
+	if err != nil {
+		return "", err
+	}
+	// no private key material to store for an external delegate; the
+	// canary is only there so SignWithDelegate can verify the token later.
+	salt, nonce, enc, kdf, err := km.encryptKey(externalDelegateCanary, token)
+	if err != nil {
+		return "", err
+	}
+	err = km.store.SetDelegate(id, parentKey, salt, nonce, enc, pubkey, kdf, 0, DelegateKindRemote, signerURL, false) // DBConflict|AlreadyExists|error
+	memZero(enc)
+	memZero(nonce)
+	memZero(salt)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (km *keyMgr) SignWithDelegate(id string, token string, sighash []byte) (sig []byte, err error) {
+	_, salt, nonce, enc, _, kdf, _, kind, signerURL, err := km.store.GetDelegatePriv(id) // NotFound|error
+	if err != nil {
+		return nil, err
+	}
+	if kind != DelegateKindRemote {
+		return nil, fmt.Errorf("delegate %q is not an external delegate", id)
+	}
+	canary, err := km.decryptKey(salt, nonce, enc, kdf, token) // WrongPassword
+	memZero(enc)
+	memZero(salt)
+	memZero(nonce)
+	if err != nil {
+		memZero(canary)
+		if errors.Is(err, ErrWrongPassword) {
+			err = ErrWrongToken
+		}
+		return nil, err
+	}
+	memZero(canary)
+	sgnr, err := signer.Dial(signerURL)
+	if err != nil {
+		return nil, err
+	}
+	return sgnr.Sign(sighash)
+}
+
+// SignDelegate signs `payload` with delegate `id` (local or external),
+// returning a compact-encoded signature and the delegate's public key. The
+// private key never leaves this process: for a local delegate it's decrypted
+// only for the duration of the Sign call; for an external delegate the
+// digest is forwarded to the signer, same as SignWithDelegate.
+func (km *keyMgr) SignDelegate(id string, token string, payload []byte, hash string) (sig []byte, pub []byte, err error) {
+	digest, err := hashPayload(hash, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, salt, nonce, enc, pub, kdf, _, kind, signerURL, err := km.store.GetDelegatePriv(id) // NotFound|error
+	if err != nil {
+		return nil, nil, err
+	}
+	if kind == DelegateKindRemote {
+		canary, err := km.decryptKey(salt, nonce, enc, kdf, token) // WrongPassword
+		memZero(enc)
+		memZero(salt)
+		memZero(nonce)
+		memZero(canary)
+		if err != nil {
+			if errors.Is(err, ErrWrongPassword) {
+				err = ErrWrongToken
+			}
+			return nil, nil, err
+		}
+		sgnr, err := signer.Dial(signerURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		sig, err = sgnr.Sign(digest)
+		return sig, pub, err
+	}
+	priv, err := km.decryptKey(salt, nonce, enc, kdf, token) // WrongPassword
+	memZero(enc)
+	memZero(salt)
+	memZero(nonce)
+	if err != nil {
+		memZero(priv)
+		if errors.Is(err, ErrWrongPassword) {
+			err = ErrWrongToken
+		}
+		return nil, nil, err
+	}
+	privKey := secp256k1.PrivKeyFromBytes(priv)
+	memZero(priv)
+	sig = ecdsa.SignCompact(privKey, digest, true)
+	privKey.Zero()
+	return sig, pub, nil
+}
+
+// VerifyDelegate checks `sig` (as returned by SignDelegate) against `payload`
+// and delegate `id`'s recorded public key, using the same hash algorithm the
+// signature was produced with.
+func (km *keyMgr) VerifyDelegate(id string, payload []byte, sig []byte, hash string) (ok bool, err error) {
+	digest, err := hashPayload(hash, payload)
+	if err != nil {
+		return false, err
+	}
+	pub, _, _, _, _, err := km.store.GetDelegatePub(id) // NotFound|error
+	if err != nil {
+		return false, err
+	}
+	recovered, _, err := ecdsa.RecoverCompact(sig, digest)
+	if err != nil {
+		return false, nil
+	}
+	return bytes.Equal(recovered.SerializeCompressed(), pub), nil
+}
+
+// attestHeader is the header of a delegate attestation token, as produced by
+// AttestDelegate. Unlike jwt.Sign's EdDSA session tokens, delegate keys are
+// secp256k1, so it can't reuse the jwt package's header/Sign: its Alg is
+// fixed to "EdDSA" for DKM's own tokens.
+type attestHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// AttestDelegate issues a short-lived, JWT-shaped attestation token proving
+// control of delegate id's key (local or external), for a relying party --
+// another pup, or an external service -- to verify offline against
+// /delegate/.well-known/jwks.json. claims becomes the token body verbatim;
+// the /delegate/authorize handler is responsible for populating
+// iss/sub/aud/nonce/iat/exp/pub before calling this.
+//
+// The signature is the same compact, recoverable secp256k1 ECDSA signature
+// SignDelegate produces, not a strict RFC 7515 ES256K JWS (which requires a
+// raw 64-byte r||s encoding with no recovery id): reusing SignDelegate's
+// exact signing primitive keeps delegate keys signing one format throughout
+// DKM, at the cost of a relying party needing to decode DKM's compact
+// encoding rather than handing the token to a stock JWT library.
+func (km *keyMgr) AttestDelegate(id string, token string, claims map[string]any) (string, error) {
+	hdr, err := json.Marshal(attestHeader{Alg: "ES256K", Typ: "JWT", Kid: id})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := b64url(hdr) + "." + b64url(body)
+	sig, _, err := km.SignDelegate(id, token, []byte(signingInput), HashSHA256)
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + b64url(sig), nil
+}
+
+func b64url(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashPayload digests `payload` for SignDelegate/VerifyDelegate according to
+// `hash` ("" defaults to HashSHA256).
+func hashPayload(hash string, payload []byte) ([]byte, error) {
+	switch hash {
+	case "", HashSHA256:
+		sum := sha256.Sum256(payload)
+		return sum[:], nil
+	case HashDogecoinMessage:
+		return dogecoinMessageHash(payload), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm: %q", hash)
+	}
+}
+
+// dogecoinMessageHash reproduces Dogecoin Core's "Signed Message" digest:
+// double-SHA256 of 0x19 "Dogecoin Signed Message:\n" <varint len> <msg>.
+func dogecoinMessageHash(msg []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(0x19)
+	buf.WriteString(dogecoinMessagePrefix)
+	writeVarInt(&buf, uint64(len(msg)))
+	buf.Write(msg)
+	first := sha256.Sum256(buf.Bytes())
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+// writeVarInt writes n as a Bitcoin/Dogecoin-style CompactSize integer.
+func writeVarInt(buf *bytes.Buffer, n uint64) {
+	switch {
+	case n < 0xfd:
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(0xfd)
+		buf.WriteByte(byte(n))
+		buf.WriteByte(byte(n >> 8))
+	case n <= 0xffffffff:
+		buf.WriteByte(0xfe)
+		for i := 0; i < 4; i++ {
+			buf.WriteByte(byte(n >> (8 * i)))
+		}
+	default:
+		buf.WriteByte(0xff)
+		for i := 0; i < 8; i++ {
+			buf.WriteByte(byte(n >> (8 * i)))
+		}
+	}
+}
+
+func (km *keyMgr) MakeDelegate(ctx context.Context, id string, token string) (privkey []byte, pubkey []byte, wif string, e error) {
 	km.cleanSessions()
-	if _, ok := km.sessions[token]; ok && km.key != nil {
-		master, err := doge.DecodeBip32WIF(string(km.key), &doge.DogeMainNetChain) // bad-key
+	caller := audit.CallerFromContext(ctx)
+	claims, verr := km.VerifyToken(token)
+	if verr != nil {
+		return nil, nil, "", ErrBadToken
+	}
+	if s, ok := km.sessions[claims.Jti]; ok {
+		key, hasKey := km.keys[s.keyName]
+		parentKey, hasID := km.keyIDs[s.keyName]
+		if !hasKey || !hasID {
+			return nil, nil, "", ErrBadToken
+		}
+		master, err := doge.DecodeBip32WIF(string(key), &doge.DogeMainNetChain) // bad-key
 		if err != nil {
 			log.Printf("CreateDelegate: error decoding master key: %v", err)
 			return nil, nil, "", ErrBadKey
@@ -245,22 +841,27 @@ func (km *keyMgr) MakeDelegate(id string, token string) (privkey []byte, pubkey
 			return nil, nil, "", ErrBadKey
 		}
 		defer pupKey.Clear() // clear key material at exit
+		var opErr error
 		err = km.store.Transaction(func(tx internal.StoreTxn) error {
-			_, keyIndex, err := tx.GetDelegatePub(id) // NotFound|error
+			_, _, keyIndex, kind, _, err := tx.GetDelegatePub(id) // NotFound|error
 			if err != nil {
 				if errors.Is(err, internal.ErrNotFound) {
-					max, err := tx.GetMaxDelegate()
+					max, err := tx.GetMaxDelegate(parentKey)
 					if err != nil {
 						return err
 					}
 					keyIndex = uint32(max + 1)
-					err = tx.SetDelegate(id, []byte{}, []byte{}, []byte{}, []byte{}, keyIndex) // DBConflict|error
+					err = tx.SetDelegate(id, parentKey, []byte{}, []byte{}, []byte{}, []byte{}, []byte{}, keyIndex, DelegateKindLocal, "", false) // DBConflict|error
 					if err != nil {
 						return err
 					}
 				} else {
 					return err
 				}
+			} else if kind != DelegateKindLocal {
+				// record the rejected attempt; nothing else to roll back.
+				opErr = ErrExternalDelegate
+				return tx.AppendAudit(audit.Event{Op: "MakeDelegate", KeyID: parentKey, DelegateID: id, Caller: caller, Outcome: "external-delegate"})
 			}
 			child, err := pupKey.PrivateCKD([]uint32{H + keyIndex}, true)
 			if err != nil {
@@ -272,6 +873,9 @@ func (km *keyMgr) MakeDelegate(id string, token string) (privkey []byte, pubkey
 			if err != nil {
 				return err
 			}
+			if aerr := tx.AppendAudit(audit.Event{Op: "MakeDelegate", KeyID: parentKey, DelegateID: id, Caller: caller, Outcome: "ok"}); aerr != nil {
+				return aerr
+			}
 			// return values:
 			privkey = priv[:]
 			pubkey = pub[:]
@@ -281,6 +885,9 @@ func (km *keyMgr) MakeDelegate(id string, token string) (privkey []byte, pubkey
 		if err != nil {
 			return nil, nil, "", err
 		}
+		if opErr != nil {
+			return nil, nil, "", opErr
+		}
 		return
 	} else {
 		// the token has already expired.
@@ -289,27 +896,74 @@ func (km *keyMgr) MakeDelegate(id string, token string) (privkey []byte, pubkey
 }
 
 func (km *keyMgr) GetDelegatePub(id string) (pubkey []byte, err error) {
-	pub, _, err := km.store.GetDelegatePub(id)
+	pub, _, _, _, _, err := km.store.GetDelegatePub(id)
 	return pub, err // NotFound|error
 }
 
-func (km *keyMgr) GetDelegatePriv(id string, token string) (privkey, pubkey []byte, err error) {
-	salt, nonce, enc, pub, err := km.store.GetDelegatePriv(id) // NotFound|error
-	if err != nil {
-		return nil, nil, err
+func (km *keyMgr) GetDelegatePriv(ctx context.Context, id string, token string) (privkey, pubkey []byte, err error) {
+	caller := audit.CallerFromContext(ctx)
+	if !jwt.HasScope(jwt.ScopeFromContext(ctx), ScopeDelegateRead) {
+		if aerr := km.store.AppendAudit(audit.Event{Op: "GetDelegatePriv", DelegateID: id, Caller: caller, Outcome: "forbidden"}); aerr != nil {
+			log.Printf("GetDelegatePriv: failed to append audit event: %v", aerr)
+		}
+		return nil, nil, ErrForbiddenScope
 	}
-	priv, err := km.decryptKey(salt, nonce, enc, token) // WrongPassword
-	memZero(enc)
-	memZero(salt)
-	memZero(nonce)
-	if err != nil {
-		memZero(priv)
-		if errors.Is(err, ErrWrongPassword) {
-			err = ErrWrongToken
+	var opErr error
+	err = km.store.Transaction(func(tx internal.StoreTxn) error {
+		parentKey, salt, nonce, enc, pub, kdf, keyid, kind, signerURL, terr := tx.GetDelegatePriv(id) // NotFound|error
+		if internal.IsDBConflictError(terr) {
+			return terr // retry the whole attempt; nothing to audit yet
+		}
+		if terr != nil {
+			opErr = terr
+			return tx.AppendAudit(audit.Event{Op: "GetDelegatePriv", DelegateID: id, Caller: caller, Outcome: loginOutcome(terr)})
+		}
+		if kind != DelegateKindLocal {
+			_ = signerURL
+			opErr = ErrExternalDelegate
+			return tx.AppendAudit(audit.Event{Op: "GetDelegatePriv", KeyID: parentKey, DelegateID: id, Caller: caller, Outcome: "external-delegate"})
+		}
+		priv, terr := km.decryptKey(salt, nonce, enc, kdf, token) // WrongPassword
+		memZero(enc)
+		memZero(salt)
+		memZero(nonce)
+		if terr != nil {
+			memZero(priv)
+			if errors.Is(terr, ErrWrongPassword) {
+				terr = ErrWrongToken
+			}
+			opErr = terr
+			return tx.AppendAudit(audit.Event{Op: "GetDelegatePriv", KeyID: parentKey, DelegateID: id, Caller: caller, Outcome: "wrong-token"})
 		}
+		// rehash in the same transaction if the stored KDF parameters are
+		// weaker than the current defaults.
+		if decodeKDFParams(kdf).weaker(getDefaultKDFParams()) {
+			newSalt, newNonce, newEnc, newKDF, rerr := km.encryptKey(priv, token)
+			if rerr != nil {
+				log.Printf("GetDelegatePriv: failed to rehash delegate %q: %v", id, rerr)
+			} else {
+				if rerr := tx.SetDelegate(id, parentKey, newSalt, newNonce, newEnc, pub, newKDF, keyid, kind, "", true); rerr != nil {
+					log.Printf("GetDelegatePriv: failed to rehash delegate %q: %v", id, rerr)
+				}
+				memZero(newSalt)
+				memZero(newNonce)
+				memZero(newEnc)
+			}
+		}
+		if aerr := tx.AppendAudit(audit.Event{Op: "GetDelegatePriv", KeyID: parentKey, DelegateID: id, Caller: caller, Outcome: "ok"}); aerr != nil {
+			return aerr
+		}
+		privkey = priv
+		pubkey = pub
+		return nil
+	})
+	if err != nil {
 		return nil, nil, err
 	}
-	return priv, pub, nil
+	if opErr != nil {
+		return nil, nil, opErr
+	}
+	return privkey, pubkey, nil
 }
 
 // HELPERS
@@ -325,48 +979,140 @@ func generateToken() (string, error) {
 	return token, nil
 }
 
-// cleanSessions removes expired sessions from memory.
+// cleanSessions removes expired sessions from memory, and zeroes any
+// decrypted master key that no live session still references.
 func (km *keyMgr) cleanSessions() {
-	// clean out expired tokens.
 	now := time.Now()
-	for key, s := range km.sessions {
+	for token, s := range km.sessions {
 		if s.expires.Before(now) {
 			// seems safe: https://go.dev/doc/effective_go#for
-			delete(km.sessions, key)
+			delete(km.sessions, token)
+		}
+	}
+	live := make(map[string]bool, len(km.sessions))
+	for _, s := range km.sessions {
+		live[s.keyName] = true
+	}
+	for name := range km.keys {
+		if !live[name] {
+			km.forgetKey(name)
+		}
+	}
+}
+
+func (km *keyMgr) forgetKey(name string) {
+	memZero(km.keys[name])
+	delete(km.keys, name)
+	delete(km.keyIDs, name)
+}
+
+func (km *keyMgr) newSession(keyName string, scope []string) (token string, ends int, err error) {
+	jti, err := generateToken()
+	if err != nil {
+		return "", 0, err
+	}
+	priv, kid, err := km.signingKeyPair()
+	if err != nil {
+		return "", 0, err
+	}
+	now := time.Now()
+	exp := now.Add(time.Duration(km.sessionTTL) * time.Second)
+	claims := jwt.Claims{
+		Sub:   keyName,
+		Iat:   now.Unix(),
+		Exp:   exp.Unix(),
+		Jti:   jti,
+		Scope: scope,
+	}
+	token, err = jwt.Sign(priv, kid, claims)
+	if err != nil {
+		return "", 0, err
+	}
+	km.sessions[jti] = session{keyName: keyName, scope: scope, issued: now, expires: exp.Add(HandoverTime * time.Second)}
+	if perr := km.store.PutSession(internal.Session{Jti: jti, KeyName: keyName, Scope: scope, Issued: now, Expires: exp, LastUsed: now}); perr != nil {
+		log.Printf("newSession: failed to persist session: %v", perr)
+	}
+	return token, km.sessionTTL, nil
+}
+
+// signingKeyPair returns the server's JWT signing key, loading (and creating,
+// on first use) it from the store on first access and caching it thereafter.
+func (km *keyMgr) signingKeyPair() (priv ed25519.PrivateKey, kid string, err error) {
+	km.signingKeyMu.Lock()
+	defer km.signingKeyMu.Unlock()
+	if km.signingKey == nil {
+		priv, kid, err := km.store.GetOrCreateSigningKey()
+		if err != nil {
+			return nil, "", err
 		}
+		km.signingKey = priv
+		km.signingKid = kid
+	}
+	return km.signingKey, km.signingKid, nil
+}
+
+// VerifyToken checks a session token's signature, expiry and revocation
+// status, returning its claims if it's still valid.
+func (km *keyMgr) VerifyToken(token string) (claims jwt.Claims, err error) {
+	priv, _, err := km.signingKeyPair()
+	if err != nil {
+		return jwt.Claims{}, err
+	}
+	claims, err = jwt.Verify(priv.Public().(ed25519.PublicKey), token)
+	if err != nil {
+		return claims, err
+	}
+	revoked, err := km.store.IsJTIRevoked(claims.Jti)
+	if err != nil {
+		return claims, err
+	}
+	if revoked {
+		return claims, ErrBadToken
+	}
+	if terr := km.store.TouchSession(claims.Jti, time.Now()); terr != nil {
+		log.Printf("VerifyToken: failed to update session last-used: %v", terr)
 	}
+	return claims, nil
 }
 
-func (km *keyMgr) newSession() (token string, ends int, err error) {
-	// generate a cryptographically-secure random token.
-	tok := [16]byte{}
-	_, err = rand.Read(tok[:])
+// SigningPublicKey returns the public half of the key session tokens are
+// signed with, and its `kid`, for serving at /jwks.json.
+func (km *keyMgr) SigningPublicKey() (pub ed25519.PublicKey, kid string, err error) {
+	priv, kid, err := km.signingKeyPair()
 	if err != nil {
-		return "", 0, ErrOutOfEntropy
+		return nil, "", err
 	}
-	token = hex.EncodeToString(tok[:])
-	km.sessions[token] = session{expires: time.Now().Add((SessionTime + HandoverTime) * time.Second)}
-	return token, SessionTime, nil
+	return priv.Public().(ed25519.PublicKey), kid, nil
 }
 
-func (km *keyMgr) getAndDecryptKey(keyId int, pass string) (key []byte, pub []byte, err error) {
-	salt, nonce, enc, pubk, err := km.store.GetKey(keyId) // ErrNotFound|error
+func (km *keyMgr) getAndDecryptKeyByName(ctx internal.StoreCtx, name string, pass string) (id int, key []byte, pub []byte, err error) {
+	id, salt, nonce, enc, pubk, kdf, err := ctx.GetKeyByName(name) // ErrNotFound|error
 	if err != nil {
 		if errors.Is(err, internal.ErrNotFound) {
-			return nil, nil, ErrNoKey
+			return 0, nil, nil, ErrNoKey
 		}
-		return nil, nil, err
+		return 0, nil, nil, err
 	}
-	dec, err := km.decryptKey(salt, nonce, enc, pass) // ErrWrongPassword|error
+	dec, err := km.decryptKey(salt, nonce, enc, kdf, pass) // ErrWrongPassword|error
 	memZero(enc)
 	memZero(salt)
 	memZero(nonce)
-	return dec, pubk, err
+	if err != nil {
+		return id, dec, pubk, err
+	}
+	// transparently raise the stored KDF cost to current defaults, if needed.
+	if decodeKDFParams(kdf).weaker(getDefaultKDFParams()) {
+		if rerr := km.encryptAndSetKey(ctx, id, name, dec, pubk, pass, true); rerr != nil {
+			log.Printf("getAndDecryptKeyByName: failed to rehash key %q: %v", name, rerr)
+		}
+	}
+	return id, dec, pubk, nil
 }
 
-func (km *keyMgr) decryptKey(salt []byte, nonce []byte, enc []byte, pass string) (key []byte, err error) {
+func (km *keyMgr) decryptKey(salt []byte, nonce []byte, enc []byte, kdf []byte, pass string) (key []byte, err error) {
+	params := decodeKDFParams(kdf)
 	// decrypt the private key using the password (via Argon2id)
-	pwdKey := argon2.IDKey([]byte(pass), salt, ArgonTime, ArgonMemory, ArgonThreads, chacha20poly1305.KeySize)
+	pwdKey := argon2.IDKey([]byte(pass), salt, params.Time, params.Memory, params.Threads, chacha20poly1305.KeySize)
 	memZero(salt)
 	aead, err := chacha20poly1305.NewX(pwdKey[:]) // bad-key-len
 	memZero(pwdKey)
@@ -387,44 +1133,46 @@ func (km *keyMgr) decryptKey(salt []byte, nonce []byte, enc []byte, pass string)
 }
 
 // encrypt secret with password and store. clears secret.
-func (km *keyMgr) encryptAndSetKey(keyId int, secret, pub []byte, pass string, allowReplace bool) (err error) {
-	salt, nonce, enc, err := km.encryptKey(secret, pass)
+func (km *keyMgr) encryptAndSetKey(ctx internal.StoreCtx, keyId int, name string, secret, pub []byte, pass string, allowReplace bool) (err error) {
+	salt, nonce, enc, kdf, err := km.encryptKey(secret, pass)
 	if err != nil {
 		return err
 	}
-	// store the password nonce, key nonce, encrypted key
-	err = km.store.SetKey(keyId, salt, nonce, enc, pub, allowReplace)
+	// store the password nonce, key nonce, encrypted key, kdf params
+	err = ctx.SetKey(keyId, name, salt, nonce, enc, pub, kdf, allowReplace)
 	memZero(enc)
 	memZero(nonce)
 	memZero(salt)
 	return err
 }
 
-// encrypt secret with password.
-func (km *keyMgr) encryptKey(secret []byte, pass string) (salt, nonce, enc []byte, err error) {
+// encrypt secret with password, using the current default KDF parameters.
+func (km *keyMgr) encryptKey(secret []byte, pass string) (salt, nonce, enc, kdf []byte, err error) {
+	params := getDefaultKDFParams()
+
 	// generate salts
-	salt = make([]byte, 16)
+	salt = make([]byte, params.SaltLen)
 	_, err = rand.Read(salt)
 	if err != nil {
-		return nil, nil, nil, ErrOutOfEntropy
+		return nil, nil, nil, nil, ErrOutOfEntropy
 	}
 	nonce = make([]byte, chacha20poly1305.NonceSizeX)
 	_, err = rand.Read(nonce)
 	if err != nil {
-		return nil, nil, nil, ErrOutOfEntropy
+		return nil, nil, nil, nil, ErrOutOfEntropy
 	}
 
 	// encrypt the private key with the password (via Argon2id)
-	pwdKey := argon2.IDKey([]byte(pass), salt, ArgonTime, ArgonMemory, ArgonThreads, chacha20poly1305.KeySize)
+	pwdKey := argon2.IDKey([]byte(pass), salt, params.Time, params.Memory, params.Threads, chacha20poly1305.KeySize)
 	aead, err := chacha20poly1305.NewX(pwdKey) // bad-key-len
 	memZero(pwdKey)                            // minimum exposure time
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 	enc = make([]byte, 0, SecretKeySize*2) // to avoid realloc (includes Poly1305 tag)
 	enc = aead.Seal(enc, nonce, secret, nil)
 
-	return salt, nonce, enc, err
+	return salt, nonce, enc, encodeKDFParams(params), err
 }
 
 func (km *keyMgr) generateMnemonic() (mnemonic []string, seed []byte, pub []byte, err error) {
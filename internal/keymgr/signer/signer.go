@@ -0,0 +1,34 @@
+// Package signer lets keymgr forward signing requests to an external signer
+// (a hardware wallet daemon, a remote HSM, ...) for "external" delegates that
+// never have their private key material loaded into this process. Dial picks
+// an implementation by the scheme of the signer URL stored alongside the
+// delegate; only "unix://" (a Unix-domain socket speaking the protocol in
+// unixsocket.go) is implemented so far.
+package signer
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Signer forwards a signing request to an external key-custody service.
+type Signer interface {
+	// Sign asks the external signer to sign `sighash`, returning the raw
+	// signature bytes. The private key never leaves the external signer.
+	Sign(sighash []byte) (sig []byte, err error)
+}
+
+// Dial connects to the signer addressed by `signerURL`, chosen by the URL's
+// scheme (e.g. "unix://").
+func Dial(signerURL string) (Signer, error) {
+	u, err := url.Parse(signerURL)
+	if err != nil {
+		return nil, fmt.Errorf("signer: bad signer URL: %w", err)
+	}
+	switch u.Scheme {
+	case "unix":
+		return newUnixSigner(u)
+	default:
+		return nil, fmt.Errorf("signer: unsupported signer scheme: %v", u.Scheme)
+	}
+}
@@ -0,0 +1,56 @@
+package signer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// unixSigner forwards Sign requests to a hardware-wallet or remote-HSM daemon
+// listening on a Unix-domain socket, using a tiny one-request-one-response
+// JSON-RPC protocol: a single JSON object is written and a single JSON object
+// is read back, one connection per Sign call.
+type unixSigner struct {
+	path string
+}
+
+type signRequest struct {
+	Method  string `json:"method"`
+	SigHash []byte `json:"sighash"`
+}
+
+type signResponse struct {
+	Signature []byte `json:"signature"`
+	Error     string `json:"error,omitempty"`
+}
+
+func newUnixSigner(u *url.URL) (*unixSigner, error) {
+	path := u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+	if path == "" {
+		return nil, fmt.Errorf("signer: unix signer URL has no path: %v", u)
+	}
+	return &unixSigner{path: path}, nil
+}
+
+func (s *unixSigner) Sign(sighash []byte) (sig []byte, err error) {
+	conn, err := net.Dial("unix", s.path)
+	if err != nil {
+		return nil, fmt.Errorf("signer: dialing %v: %w", s.path, err)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(signRequest{Method: "sign", SigHash: sighash}); err != nil {
+		return nil, fmt.Errorf("signer: sending request: %w", err)
+	}
+	var res signResponse
+	if err := json.NewDecoder(conn).Decode(&res); err != nil {
+		return nil, fmt.Errorf("signer: reading response: %w", err)
+	}
+	if res.Error != "" {
+		return nil, fmt.Errorf("signer: remote signer error: %v", res.Error)
+	}
+	return res.Signature, nil
+}
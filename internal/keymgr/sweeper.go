@@ -0,0 +1,38 @@
+package keymgr
+
+import (
+	"log"
+	"time"
+
+	"code.dogecoin.org/dkm/internal"
+	"code.dogecoin.org/governor"
+)
+
+// SessionSweeper periodically deletes expired rows from the `session`
+// table, so it doesn't grow without bound. Registered by main.go alongside
+// the web server; New's in-memory session map is cleaned up separately, as
+// sessions are used (see cleanSessions).
+type SessionSweeper struct {
+	governor.ServiceCtx
+	store internal.StoreCtx
+	every time.Duration
+}
+
+// NewSweeper sweeps expired sessions every `ttl` seconds (the same
+// --session-ttl passed to keymgr.New), since a session can't go stale any
+// faster than that.
+func NewSweeper(store internal.StoreCtx, ttl int) governor.Service {
+	if ttl <= 0 {
+		ttl = SessionTime
+	}
+	return &SessionSweeper{store: store, every: time.Duration(ttl) * time.Second}
+}
+
+// goroutine
+func (s *SessionSweeper) Run() {
+	for !s.Sleep(s.every) {
+		if err := s.store.PruneSessions(time.Now()); err != nil {
+			log.Printf("SessionSweeper: %v", err)
+		}
+	}
+}
@@ -2,22 +2,138 @@ package internal
 
 import (
 	"context"
+	"crypto/ed25519"
 	"errors"
+	"time"
+
+	"code.dogecoin.org/dkm/internal/audit"
 )
 
-// Store is the top-level interface (e.g. SQLiteStore)
+// Store is the top-level interface for a storage backend (e.g. SQLiteStore,
+// PostgresStore). Concrete backends live under internal/store/<driver> and
+// register themselves with the internal/store driver registry.
 type Store interface {
 	WithCtx(ctx context.Context) StoreCtx
 }
 
 // StoreCtx is a Store bound to a cancellable Context
 type StoreCtx interface {
-	SetKey(id int, s1, s2, enc, pub []byte, allowReplace bool) error
-	GetKey(id int) (s1, s2, enc, pub []byte, err error)
+	// NextKeyID allocates the next unused `config.id`, for a new named key.
+	NextKeyID() (id int, err error)
+	// `kdf` carries a small JSON header describing the KDF algorithm and
+	// parameters used to derive the password key for this record (see
+	// keymgr.KDFParams), so they can be raised over time without
+	// invalidating already-stored keys.
+	SetKey(id int, name string, salt, nonce, enc, pub, kdf []byte, allowReplace bool) error
+	GetKey(id int) (name string, salt, nonce, enc, pub, kdf []byte, err error)
+	GetKeyByName(name string) (id int, salt, nonce, enc, pub, kdf []byte, err error)
 	GetKeyPub(id int) (pub []byte, err error)
-	SetDelegate(id string, s1, s2, enc, pub []byte) (err error)
-	GetDelegatePriv(id string) (s1, s2, enc, pub []byte, err error)
-	GetDelegatePub(id string) (pub []byte, err error)
+	// ListKeys returns the name of every key currently stored.
+	ListKeys() (names []string, err error)
+	DeleteKey(id int) error
+	// SetDelegate stores a delegate unlocked under the key `parentKey` (a
+	// config.id). `kind` is "local" (a BIP32 child key derived from the
+	// parent and encrypted with salt/nonce/enc/kdf) or "remote" (no private
+	// key material held locally; `signer` is the URL of the external signer
+	// that holds it, e.g. a hardware wallet or HSM). allowReplace permits
+	// overwriting an existing delegate's encrypted material in place (e.g.
+	// to rehash it to current KDF parameters), keeping its parent_key,
+	// keyid, kind and signer unchanged.
+	SetDelegate(id string, parentKey int, salt, nonce, enc, pub, kdf []byte, keyid uint32, kind string, signer string, allowReplace bool) (err error)
+	GetDelegatePriv(id string) (parentKey int, salt, nonce, enc, pub, kdf []byte, keyid uint32, kind string, signer string, err error)
+	GetDelegatePub(id string) (pub []byte, parentKey int, keyid uint32, kind string, signer string, err error)
+	// GetMaxDelegate returns the highest delegate derivation index allocated
+	// under `parentKey` so far, since each parent key has its own m/1000'/2'/N' namespace.
+	GetMaxDelegate(parentKey int) (max uint32, err error)
+	AuditLog
+	TokenStore
+	SessionStore
+	// Transaction runs `work` inside a single database transaction, retrying
+	// automatically when it returns ErrDBConflict (e.g. SQLite SQLITE_BUSY,
+	// Postgres serialization failures).
+	Transaction(work func(tx StoreTxn) error) error
+}
+
+// TokenStore backs keymgr's session JWTs: the server-held signing key they're
+// issued under, and the revocation set that lets DKM remain the source of
+// truth for whether a (stateless) JWT is still valid.
+type TokenStore interface {
+	// GetOrCreateSigningKey returns the server's persistent Ed25519 JWT
+	// signing key and its key ID (for JWK `kid`), generating and storing one
+	// on first use.
+	GetOrCreateSigningKey() (priv ed25519.PrivateKey, kid string, err error)
+	// RevokeJTI marks a JWT id as revoked, so it's rejected by VerifyToken
+	// even though it hasn't reached its own `exp` claim yet. `expires`
+	// should be the token's own exp, so the revocation record can be pruned
+	// once the token would have expired anyway.
+	RevokeJTI(jti string, expires time.Time) error
+	// IsJTIRevoked reports whether `jti` has been revoked.
+	IsJTIRevoked(jti string) (revoked bool, err error)
+}
+
+// Session is one row of session metadata, as persisted by SessionStore and
+// returned by keymgr's /sessions endpoint for observability. It never
+// carries the decrypted master key a session grants access to: that only
+// ever exists in keymgr's own memory for the lifetime of a login, the same
+// as it did before sessions were persisted. Reloading a Session on startup
+// therefore restores the bookkeeping (so e.g. LogOut and RollToken work
+// against it immediately) but not the ability to mint new delegates from
+// it until the operator (or an auto-unseal secret) logs in again.
+type Session struct {
+	Jti      string
+	KeyName  string
+	Scope    []string
+	Issued   time.Time
+	Expires  time.Time
+	LastUsed time.Time
+}
+
+// SessionStore persists keymgr session metadata so that a session's
+// bookkeeping (its scope, expiry, and last-used time) survives a DKM
+// restart: RollToken, LogOut and the /sessions endpoint all work against a
+// reloaded session immediately. It does NOT let a client skip logging in
+// again -- see Session's doc comment for why the decrypted master key
+// itself is never persisted -- so a reloaded session can't mint new
+// delegates until a fresh login (or auto-unseal) repopulates it.
+type SessionStore interface {
+	// PutSession creates or replaces the row for `s.Jti`.
+	PutSession(s Session) error
+	// TouchSession updates a session's last-used timestamp. Not finding the
+	// row is not an error: the caller only has a best-effort interest in
+	// last-used tracking.
+	TouchSession(jti string, used time.Time) error
+	// DeleteSession removes a session row, e.g. on logout or roll.
+	DeleteSession(jti string) error
+	// ListSessions returns every session row, expired or not; callers
+	// filter by Expires themselves (keymgr.New only reloads unexpired
+	// ones, while the /sessions endpoint reports all of them).
+	ListSessions() (sessions []Session, err error)
+	// PruneSessions deletes every session that expired before `now`.
+	PruneSessions(now time.Time) error
+}
+
+// AuditLog is an append-only, hash-chained log of sensitive key-material
+// operations (logins, password changes, delegate creation/use, and their
+// failures). Every keymgr method that changes state calls AppendAudit
+// inside the same StoreCtx.Transaction as the change itself, so an audit
+// entry can't be lost on crash independently of the change it documents.
+type AuditLog interface {
+	// AppendAudit records one audit event, chaining it from the current
+	// last entry's hash so tampering with stored entries is detectable.
+	AppendAudit(ev audit.Event) error
+	// StreamAudit returns every audit record appended at or after `since`,
+	// oldest first.
+	StreamAudit(since time.Time) (records []audit.Record, err error)
+	// VerifyAuditChain recomputes every record's hash-chain link and
+	// compares it against what's stored, returning the id of the first
+	// record that doesn't match (tampered or corrupt), or -1 if the whole
+	// chain is intact.
+	VerifyAuditChain() (brokenID int64, err error)
+}
+
+// StoreTxn is a StoreCtx bound to an in-progress transaction.
+type StoreTxn interface {
+	StoreCtx
 }
 
 var ErrNotFound = errors.New("store: not found")
@@ -0,0 +1,93 @@
+// Package migrations is a small versioned-schema-upgrade helper shared by
+// the store drivers (store/sqlite, store/postgres, store/sqlcipher). Each
+// driver defines its own ordered []Migration in its own SQL dialect and
+// calls MigrateUp from its New() function; this package only tracks which
+// versions have been applied and runs the remaining ones in order.
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Migration is a single schema upgrade, applied inside its own transaction.
+// Up may run DDL (schema changes) or DML (data backfills) against `tx`;
+// anything it returns an error for rolls back and aborts the upgrade.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *sql.Tx) error
+}
+
+const SCHEMA_VERSION_TABLE = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER NOT NULL
+);
+`
+
+// CurrentVersion returns the highest version recorded in schema_version, or
+// 0 if the table is empty (a freshly created database, or one predating
+// this package). Assumes the schema_version table already exists.
+func CurrentVersion(db *sql.DB) (version int, err error) {
+	row := db.QueryRow("SELECT COALESCE(MAX(version),0) FROM schema_version")
+	err = row.Scan(&version)
+	return
+}
+
+// MigrateUp brings `db` up to the latest version in `all`. Safe to call on
+// every store.New: a database already at the latest version is a no-op.
+func MigrateUp(db *sql.DB, all []Migration) error {
+	return MigrateTo(db, all, maxVersion(all))
+}
+
+// MigrateTo applies every migration in `all` with Version in
+// (current, target], in ascending order, each in its own transaction. Used
+// by MigrateUp, and directly by a `--migrate-only` program mode that wants
+// to stop at a specific version.
+func MigrateTo(db *sql.DB, all []Migration, target int) error {
+	if _, err := db.Exec(SCHEMA_VERSION_TABLE); err != nil {
+		return fmt.Errorf("migrations: creating schema_version table: %w", err)
+	}
+	current, err := CurrentVersion(db)
+	if err != nil {
+		return fmt.Errorf("migrations: reading schema version: %w", err)
+	}
+	for _, m := range all {
+		if m.Version <= current || m.Version > target {
+			continue
+		}
+		if err := applyOne(db, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyOne(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("migrations: begin v%d: %w", m.Version, err)
+	}
+	defer tx.Rollback()
+	if err := m.Up(tx); err != nil {
+		return fmt.Errorf("migrations: applying v%d (%s): %w", m.Version, m.Description, err)
+	}
+	// `version` is an int from our own migration table, not user input.
+	if _, err := tx.Exec(fmt.Sprintf("INSERT INTO schema_version (version) VALUES (%d)", m.Version)); err != nil {
+		return fmt.Errorf("migrations: recording v%d: %w", m.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migrations: commit v%d: %w", m.Version, err)
+	}
+	return nil
+}
+
+func maxVersion(all []Migration) int {
+	max := 0
+	for _, m := range all {
+		if m.Version > max {
+			max = m.Version
+		}
+	}
+	return max
+}
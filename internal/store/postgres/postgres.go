@@ -0,0 +1,596 @@
+// Package postgres is the PostgreSQL storage driver, registered under the
+// "postgres" scheme with the internal/store driver registry. It exists so
+// multiple dkm instances can share state, which the single-connection
+// SQLite backend cannot support.
+package postgres
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"code.dogecoin.org/dkm/internal"
+	"code.dogecoin.org/dkm/internal/audit"
+	"code.dogecoin.org/dkm/internal/store"
+	"code.dogecoin.org/dkm/internal/store/migrations"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func init() {
+	store.Register("postgres", New)
+}
+
+// Migrations is applied in order by New(). v1 is the schema as it stood
+// before this package existed; later versions evolve it without breaking
+// already-deployed databases. See store/migrations for how this is run.
+var Migrations = []migrations.Migration{
+	{Version: 1, Description: "initial schema", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS config (
+				id INTEGER PRIMARY KEY,
+				name TEXT UNIQUE,
+				s1 BYTEA NOT NULL,
+				s2 BYTEA NOT NULL,
+				enc BYTEA NOT NULL,
+				pub BYTEA NOT NULL
+			);
+			CREATE TABLE IF NOT EXISTS delegate (
+				id TEXT PRIMARY KEY,
+				parent_key INTEGER NOT NULL DEFAULT 1,
+				s1 BYTEA NOT NULL,
+				s2 BYTEA NOT NULL,
+				enc BYTEA NOT NULL,
+				pub BYTEA NOT NULL,
+				keyid INTEGER NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS delegate_keyid_i ON delegate (keyid);
+			CREATE INDEX IF NOT EXISTS delegate_parent_key_i ON delegate (parent_key);
+		`)
+		return err
+	}},
+	{Version: 2, Description: "rename s1/s2 to salt/nonce, add per-record kdf_params", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			ALTER TABLE config RENAME COLUMN s1 TO salt;
+			ALTER TABLE config RENAME COLUMN s2 TO nonce;
+			ALTER TABLE config ADD COLUMN kdf_params BYTEA;
+			ALTER TABLE delegate RENAME COLUMN s1 TO salt;
+			ALTER TABLE delegate RENAME COLUMN s2 TO nonce;
+			ALTER TABLE delegate ADD COLUMN kdf_params BYTEA;
+		`)
+		return err
+	}},
+	{Version: 3, Description: "add delegate_kind and signer columns for external (hardware-wallet / remote-signer) delegates", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			ALTER TABLE delegate ADD COLUMN delegate_kind TEXT NOT NULL DEFAULT 'local';
+			ALTER TABLE delegate ADD COLUMN signer TEXT NOT NULL DEFAULT '';
+		`)
+		return err
+	}},
+	{Version: 4, Description: "add append-only hash-chained audit table", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS audit (
+				id BIGSERIAL PRIMARY KEY,
+				ts TIMESTAMPTZ NOT NULL,
+				op TEXT NOT NULL,
+				key_id INTEGER NOT NULL DEFAULT 0,
+				delegate_id TEXT NOT NULL DEFAULT '',
+				caller TEXT NOT NULL DEFAULT '',
+				outcome TEXT NOT NULL,
+				prev_hash BYTEA NOT NULL,
+				this_hash BYTEA NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS audit_ts_i ON audit (ts);
+		`)
+		return err
+	}},
+	{Version: 5, Description: "add signing_key and revoked_jti tables for JWT session tokens", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS signing_key (
+				id INTEGER PRIMARY KEY CHECK (id = 1),
+				kid TEXT NOT NULL,
+				priv BYTEA NOT NULL
+			);
+			CREATE TABLE IF NOT EXISTS revoked_jti (
+				jti TEXT PRIMARY KEY,
+				expires TIMESTAMPTZ NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS revoked_jti_expires_i ON revoked_jti (expires);
+		`)
+		return err
+	}},
+	{Version: 6, Description: "add sessions table so active sessions survive a restart", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS session (
+				jti TEXT PRIMARY KEY,
+				key_name TEXT NOT NULL,
+				scope TEXT NOT NULL,
+				issued TIMESTAMPTZ NOT NULL,
+				expires TIMESTAMPTZ NOT NULL,
+				last_used TIMESTAMPTZ NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS session_expires_i ON session (expires);
+		`)
+		return err
+	}},
+}
+
+type PostgresStore struct {
+	db *sql.DB
+}
+
+type PostgresStoreCtx struct {
+	_db *sql.DB
+	db  Queryable
+	ctx context.Context
+	tx  *sql.Tx // set if inside a transaction, otherwise nil
+}
+
+// The common read-only parts of sql.DB and sql.Tx, mirroring store/sqlite's Queryable.
+type Queryable interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// New opens a PostgreSQL-backed Store. `dsn` is a standard Postgres
+// connection string (e.g. "user=dkm dbname=dkm sslmode=disable" or a
+// "postgresql://" URL), as passed via the "postgres://" scheme to store.New.
+// Registered with internal/store as the "postgres" driver. Encryption at
+// rest is expected to be handled by Postgres itself; opts.Passphrase is ignored.
+func New(dsn string, opts store.Options) (internal.Store, error) {
+	db, err := sql.Open("pgx", dsn)
+	s := &PostgresStore{db: db}
+	if err != nil {
+		return s, dbErr(err, "opening database")
+	}
+	err = migrations.MigrateUp(db, Migrations)
+	if err != nil {
+		return s, dbErr(err, "migrating database schema")
+	}
+	return s, err
+}
+
+func (s *PostgresStore) Close() {
+	s.db.Close()
+}
+
+func (s *PostgresStore) WithCtx(ctx context.Context) internal.StoreCtx {
+	return &PostgresStoreCtx{
+		_db: s.db,
+		db:  s.db,
+		ctx: ctx,
+	}
+}
+
+// IsConflict reports whether `err` should cause the calling transaction to be retried:
+// either an explicit internal.ErrDBConflict, or a Postgres serialization/deadlock failure.
+func IsConflict(err error) bool {
+	if errors.Is(err, internal.ErrDBConflict) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 40001: serialization_failure, 40P01: deadlock_detected
+		if pgErr.Code == "40001" || pgErr.Code == "40P01" {
+			return true
+		}
+	}
+	return false
+}
+
+func IsConstraint(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 23505: unique_violation
+		return pgErr.Code == "23505"
+	}
+	return false
+}
+
+func (s PostgresStoreCtx) doTxn(name string, work func(tx *sql.Tx) error) error {
+	db := s._db
+	if s.tx != nil {
+		// already running inside a user-level store.Transaction,
+		// so just run the work function directly.
+		return work(s.tx)
+	}
+	limit := 120
+	for {
+		tx, err := db.BeginTx(s.ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+		if err != nil {
+			if IsConflict(err) && limit != 0 {
+				limit--
+				continue
+			}
+			return fmt.Errorf("[Store] cannot begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+		// work() may return ErrDBConflict to retry the transaction.
+		// any Postgres serialization-failure error will also retry the transaction.
+		err = work(tx)
+		if err != nil {
+			if IsConflict(err) && limit != 0 {
+				limit--
+				continue
+			}
+			return err
+		}
+		err = tx.Commit()
+		if err != nil {
+			if IsConflict(err) && limit != 0 {
+				limit--
+				continue
+			}
+			return fmt.Errorf("[Store] cannot commit %v: %w", name, err)
+		}
+		return nil
+	}
+}
+
+func dbErr(err error, where string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return internal.ErrNotFound
+	}
+	if IsConstraint(err) {
+		// MUST detect 'AlreadyExists' to fulfil the API contract!
+		return internal.ErrAlreadyExists
+	}
+	if IsConflict(err) {
+		// Treated as a transient database conflict; the caller should retry.
+		return internal.ErrDBConflict
+	}
+	return fmt.Errorf("store: %v: %w", where, err)
+}
+
+// STORE INTERFACE
+
+func (s PostgresStoreCtx) Transaction(work func(tx internal.StoreTxn) error) error {
+	return s.doTxn("txn", func(tx *sql.Tx) error {
+		stx := &PostgresStoreCtx{
+			_db: s._db,
+			db:  tx,
+			ctx: s.ctx,
+			tx:  tx,
+		}
+		return work(stx)
+	})
+}
+
+func (s PostgresStoreCtx) NextKeyID() (id int, err error) {
+	err = s.doTxn("NextKeyID", func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(s.ctx, "SELECT COALESCE(MAX(id),0)+1 FROM config")
+		err = row.Scan(&id)
+		if err != nil {
+			return dbErr(err, "NextKeyID")
+		}
+		return nil
+	})
+	return
+}
+
+func (s PostgresStoreCtx) SetKey(id int, name string, salt []byte, nonce []byte, enc []byte, pub []byte, kdf []byte, allowReplace bool) error {
+	return s.doTxn("SetKey", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(s.ctx, "INSERT INTO config (id,name,salt,nonce,enc,pub,kdf_params) VALUES ($1,$2,$3,$4,$5,$6,$7)", id, nullable(name), salt, nonce, enc, pub, kdf)
+		if err != nil {
+			if IsConstraint(err) && allowReplace {
+				// already exists and allowed to replace.
+				_, err = tx.ExecContext(s.ctx, "UPDATE config SET name=$1,salt=$2,nonce=$3,enc=$4,pub=$5,kdf_params=$6 WHERE id=$7", nullable(name), salt, nonce, enc, pub, kdf, id)
+				if err != nil {
+					return dbErr(err, "SetKey")
+				}
+				return nil
+			}
+			return dbErr(err, "SetKey") // AlreadyExists or error
+		}
+		return nil
+	})
+}
+
+func (s PostgresStoreCtx) GetKey(id int) (name string, salt []byte, nonce []byte, enc []byte, pub []byte, kdf []byte, err error) {
+	err = s.doTxn("GetKey", func(tx *sql.Tx) error {
+		var nameCol sql.NullString
+		row := tx.QueryRowContext(s.ctx, "SELECT name,salt,nonce,enc,pub,kdf_params FROM config WHERE id=$1", id)
+		err = row.Scan(&nameCol, &salt, &nonce, &enc, &pub, &kdf)
+		if err != nil {
+			return dbErr(err, "GetKey")
+		}
+		name = nameCol.String
+		return nil
+	})
+	return
+}
+
+func (s PostgresStoreCtx) GetKeyByName(name string) (id int, salt []byte, nonce []byte, enc []byte, pub []byte, kdf []byte, err error) {
+	err = s.doTxn("GetKeyByName", func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(s.ctx, "SELECT id,salt,nonce,enc,pub,kdf_params FROM config WHERE name=$1", name)
+		err = row.Scan(&id, &salt, &nonce, &enc, &pub, &kdf)
+		if err != nil {
+			return dbErr(err, "GetKeyByName")
+		}
+		return nil
+	})
+	return
+}
+
+func (s PostgresStoreCtx) GetKeyPub(id int) (pub []byte, err error) {
+	err = s.doTxn("GetKeyPub", func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(s.ctx, "SELECT pub FROM config WHERE id=$1", id)
+		err = row.Scan(&pub)
+		if err != nil {
+			return dbErr(err, "GetKeyPub")
+		}
+		return nil
+	})
+	return
+}
+
+func (s PostgresStoreCtx) ListKeys() (names []string, err error) {
+	err = s.doTxn("ListKeys", func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(s.ctx, "SELECT name FROM config WHERE name IS NOT NULL ORDER BY id")
+		if err != nil {
+			return dbErr(err, "ListKeys")
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return dbErr(err, "ListKeys")
+			}
+			names = append(names, name)
+		}
+		return rows.Err()
+	})
+	return
+}
+
+func (s PostgresStoreCtx) DeleteKey(id int) error {
+	return s.doTxn("DeleteKey", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(s.ctx, "DELETE FROM config WHERE id=$1", id)
+		if err != nil {
+			return dbErr(err, "DeleteKey")
+		}
+		return nil
+	})
+}
+
+func (s PostgresStoreCtx) SetDelegate(id string, parentKey int, salt, nonce, enc, pub, kdf []byte, keyid uint32, kind string, signer string, allowReplace bool) (err error) {
+	return s.doTxn("SetDelegate", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(s.ctx, "INSERT INTO delegate (id,parent_key,salt,nonce,enc,pub,kdf_params,keyid,delegate_kind,signer) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)", id, parentKey, salt, nonce, enc, pub, kdf, keyid, kind, signer)
+		if err != nil {
+			if IsConstraint(err) && allowReplace {
+				// already exists and allowed to replace; parent_key and keyid are unchanged.
+				_, err = tx.ExecContext(s.ctx, "UPDATE delegate SET salt=$1,nonce=$2,enc=$3,pub=$4,kdf_params=$5 WHERE id=$6", salt, nonce, enc, pub, kdf, id)
+				if err != nil {
+					return dbErr(err, "SetDelegate")
+				}
+				return nil
+			}
+			return dbErr(err, "SetDelegate") // AlreadyExists or error
+		}
+		return nil
+	})
+}
+
+func (s PostgresStoreCtx) GetDelegatePub(id string) (pub []byte, parentKey int, keyid uint32, kind string, signer string, err error) {
+	err = s.doTxn("GetDelegatePub", func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(s.ctx, "SELECT pub,parent_key,keyid,delegate_kind,signer FROM delegate WHERE id=$1", id)
+		err = row.Scan(&pub, &parentKey, &keyid, &kind, &signer)
+		if err != nil {
+			return dbErr(err, "GetDelegatePub")
+		}
+		return nil
+	})
+	return
+}
+
+func (s PostgresStoreCtx) GetDelegatePriv(id string) (parentKey int, salt, nonce, enc, pub, kdf []byte, keyid uint32, kind string, signer string, err error) {
+	err = s.doTxn("GetDelegatePriv", func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(s.ctx, "SELECT parent_key,salt,nonce,enc,pub,kdf_params,keyid,delegate_kind,signer FROM delegate WHERE id=$1", id)
+		err = row.Scan(&parentKey, &salt, &nonce, &enc, &pub, &kdf, &keyid, &kind, &signer)
+		if err != nil {
+			return dbErr(err, "GetDelegatePriv")
+		}
+		return nil
+	})
+	return
+}
+
+func (s PostgresStoreCtx) GetMaxDelegate(parentKey int) (max uint32, err error) {
+	err = s.doTxn("GetMaxDelegate", func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(s.ctx, "SELECT COALESCE(MAX(keyid),0) FROM delegate WHERE parent_key=$1", parentKey)
+		err = row.Scan(&max)
+		if err != nil {
+			return dbErr(err, "GetMaxDelegate")
+		}
+		return nil
+	})
+	return
+}
+
+func (s PostgresStoreCtx) AppendAudit(ev audit.Event) error {
+	return s.doTxn("AppendAudit", func(tx *sql.Tx) error {
+		var prevHash []byte
+		row := tx.QueryRowContext(s.ctx, "SELECT this_hash FROM audit ORDER BY id DESC LIMIT 1")
+		err := row.Scan(&prevHash)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return dbErr(err, "AppendAudit")
+		}
+		// Truncate to microseconds: that's all TIMESTAMPTZ preserves, and
+		// ComputeHash must be fed back the same value StreamAudit will read
+		// out of the column, or every record verifies as tampered.
+		ts := time.Now().UTC().Truncate(time.Microsecond)
+		thisHash := audit.ComputeHash(prevHash, ts, ev)
+		_, err = tx.ExecContext(s.ctx, "INSERT INTO audit (ts,op,key_id,delegate_id,caller,outcome,prev_hash,this_hash) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)",
+			ts, ev.Op, ev.KeyID, ev.DelegateID, ev.Caller, ev.Outcome, prevHash, thisHash)
+		if err != nil {
+			return dbErr(err, "AppendAudit")
+		}
+		return nil
+	})
+}
+
+func (s PostgresStoreCtx) StreamAudit(since time.Time) (records []audit.Record, err error) {
+	err = s.doTxn("StreamAudit", func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(s.ctx, "SELECT id,ts,op,key_id,delegate_id,caller,outcome,prev_hash,this_hash FROM audit WHERE ts>=$1 ORDER BY id", since)
+		if err != nil {
+			return dbErr(err, "StreamAudit")
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var rec audit.Record
+			if err := rows.Scan(&rec.ID, &rec.Ts, &rec.Op, &rec.KeyID, &rec.DelegateID, &rec.Caller, &rec.Outcome, &rec.PrevHash, &rec.ThisHash); err != nil {
+				return dbErr(err, "StreamAudit")
+			}
+			records = append(records, rec)
+		}
+		return rows.Err()
+	})
+	return
+}
+
+func (s PostgresStoreCtx) VerifyAuditChain() (brokenID int64, err error) {
+	records, err := s.StreamAudit(time.Time{})
+	if err != nil {
+		return -1, err
+	}
+	return audit.Verify(records), nil
+}
+
+func (s PostgresStoreCtx) GetOrCreateSigningKey() (priv ed25519.PrivateKey, kid string, err error) {
+	err = s.doTxn("GetOrCreateSigningKey", func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(s.ctx, "SELECT kid,priv FROM signing_key WHERE id=1")
+		terr := row.Scan(&kid, &priv)
+		if terr == nil {
+			return nil
+		}
+		if !errors.Is(terr, sql.ErrNoRows) {
+			return dbErr(terr, "GetOrCreateSigningKey")
+		}
+		pub, newpriv, terr := ed25519.GenerateKey(rand.Reader)
+		if terr != nil {
+			return terr
+		}
+		sum := sha256.Sum256(pub)
+		kid = hex.EncodeToString(sum[:8])
+		priv = newpriv
+		_, terr = tx.ExecContext(s.ctx, "INSERT INTO signing_key (id,kid,priv) VALUES (1,$1,$2)", kid, []byte(priv))
+		if terr != nil {
+			return dbErr(terr, "GetOrCreateSigningKey")
+		}
+		return nil
+	})
+	return
+}
+
+func (s PostgresStoreCtx) RevokeJTI(jti string, expires time.Time) error {
+	return s.doTxn("RevokeJTI", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(s.ctx, "INSERT INTO revoked_jti (jti,expires) VALUES ($1,$2) ON CONFLICT (jti) DO UPDATE SET expires=$2", jti, expires)
+		if err != nil {
+			return dbErr(err, "RevokeJTI")
+		}
+		return nil
+	})
+}
+
+func (s PostgresStoreCtx) IsJTIRevoked(jti string) (revoked bool, err error) {
+	err = s.doTxn("IsJTIRevoked", func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(s.ctx, "SELECT 1 FROM revoked_jti WHERE jti=$1", jti)
+		terr := row.Scan(new(int))
+		if terr == nil {
+			revoked = true
+			return nil
+		}
+		if errors.Is(terr, sql.ErrNoRows) {
+			return nil
+		}
+		return dbErr(terr, "IsJTIRevoked")
+	})
+	return
+}
+
+func (s PostgresStoreCtx) PutSession(sess internal.Session) error {
+	scope, err := json.Marshal(sess.Scope)
+	if err != nil {
+		return err
+	}
+	return s.doTxn("PutSession", func(tx *sql.Tx) error {
+		_, terr := tx.ExecContext(s.ctx, `
+			INSERT INTO session (jti,key_name,scope,issued,expires,last_used)
+			VALUES ($1,$2,$3,$4,$5,$6)
+			ON CONFLICT (jti) DO UPDATE SET key_name=$2,scope=$3,issued=$4,expires=$5,last_used=$6
+		`, sess.Jti, sess.KeyName, string(scope), sess.Issued, sess.Expires, sess.LastUsed)
+		if terr != nil {
+			return dbErr(terr, "PutSession")
+		}
+		return nil
+	})
+}
+
+func (s PostgresStoreCtx) TouchSession(jti string, used time.Time) error {
+	return s.doTxn("TouchSession", func(tx *sql.Tx) error {
+		_, terr := tx.ExecContext(s.ctx, "UPDATE session SET last_used=$1 WHERE jti=$2", used, jti)
+		if terr != nil {
+			return dbErr(terr, "TouchSession")
+		}
+		return nil
+	})
+}
+
+func (s PostgresStoreCtx) DeleteSession(jti string) error {
+	return s.doTxn("DeleteSession", func(tx *sql.Tx) error {
+		_, terr := tx.ExecContext(s.ctx, "DELETE FROM session WHERE jti=$1", jti)
+		if terr != nil {
+			return dbErr(terr, "DeleteSession")
+		}
+		return nil
+	})
+}
+
+func (s PostgresStoreCtx) ListSessions() (sessions []internal.Session, err error) {
+	err = s.doTxn("ListSessions", func(tx *sql.Tx) error {
+		rows, terr := tx.QueryContext(s.ctx, "SELECT jti,key_name,scope,issued,expires,last_used FROM session")
+		if terr != nil {
+			return dbErr(terr, "ListSessions")
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var sess internal.Session
+			var scope string
+			if terr := rows.Scan(&sess.Jti, &sess.KeyName, &scope, &sess.Issued, &sess.Expires, &sess.LastUsed); terr != nil {
+				return dbErr(terr, "ListSessions")
+			}
+			if terr := json.Unmarshal([]byte(scope), &sess.Scope); terr != nil {
+				return terr
+			}
+			sessions = append(sessions, sess)
+		}
+		return rows.Err()
+	})
+	return
+}
+
+func (s PostgresStoreCtx) PruneSessions(now time.Time) error {
+	return s.doTxn("PruneSessions", func(tx *sql.Tx) error {
+		_, terr := tx.ExecContext(s.ctx, "DELETE FROM session WHERE expires<$1", now)
+		if terr != nil {
+			return dbErr(terr, "PruneSessions")
+		}
+		return nil
+	})
+}
+
+// nullable maps an empty string to SQL NULL, so the `name UNIQUE` constraint
+// doesn't treat multiple unnamed keys as duplicates of each other.
+func nullable(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,618 @@
+//go:build !sqlcipher
+
+// Package sqlite is the SQLite storage driver, registered under the
+// "sqlite3" scheme with the internal/store driver registry. Binaries built
+// with `-tags sqlcipher` use store/sqlcipher instead, which registers the
+// same scheme name with at-rest encryption support.
+package sqlite
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"code.dogecoin.org/dkm/internal"
+	"code.dogecoin.org/dkm/internal/audit"
+	"code.dogecoin.org/dkm/internal/store"
+	"code.dogecoin.org/dkm/internal/store/migrations"
+	"github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	store.Register("sqlite3", New)
+}
+
+// Migrations is applied in order by New(). v1 is the schema as it stood
+// before this package existed; later versions evolve it without breaking
+// already-deployed databases. See store/migrations for how this is run.
+var Migrations = []migrations.Migration{
+	{Version: 1, Description: "initial schema", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS config (
+				id INTEGER PRIMARY KEY,
+				name TEXT UNIQUE,
+				s1 BLOB NOT NULL,
+				s2 BLOB NOT NULL,
+				enc BLOB NOT NULL,
+				pub BLOB NOT NULL
+			);
+			CREATE TABLE IF NOT EXISTS delegate (
+				id TEXT PRIMARY KEY,
+				parent_key INTEGER NOT NULL DEFAULT 1,
+				s1 BLOB NOT NULL,
+				s2 BLOB NOT NULL,
+				enc BLOB NOT NULL,
+				pub BLOB NOT NULL,
+				keyid INTEGER NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS delegate_keyid_i ON delegate (keyid);
+			CREATE INDEX IF NOT EXISTS delegate_parent_key_i ON delegate (parent_key);
+		`)
+		return err
+	}},
+	{Version: 2, Description: "rename s1/s2 to salt/nonce, add per-record kdf_params", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			ALTER TABLE config RENAME COLUMN s1 TO salt;
+			ALTER TABLE config RENAME COLUMN s2 TO nonce;
+			ALTER TABLE config ADD COLUMN kdf_params BLOB;
+			ALTER TABLE delegate RENAME COLUMN s1 TO salt;
+			ALTER TABLE delegate RENAME COLUMN s2 TO nonce;
+			ALTER TABLE delegate ADD COLUMN kdf_params BLOB;
+		`)
+		return err
+	}},
+	{Version: 3, Description: "add delegate_kind and signer columns for external (hardware-wallet / remote-signer) delegates", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			ALTER TABLE delegate ADD COLUMN delegate_kind TEXT NOT NULL DEFAULT 'local';
+			ALTER TABLE delegate ADD COLUMN signer TEXT NOT NULL DEFAULT '';
+		`)
+		return err
+	}},
+	{Version: 4, Description: "add append-only hash-chained audit table", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS audit (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				ts DATETIME NOT NULL,
+				op TEXT NOT NULL,
+				key_id INTEGER NOT NULL DEFAULT 0,
+				delegate_id TEXT NOT NULL DEFAULT '',
+				caller TEXT NOT NULL DEFAULT '',
+				outcome TEXT NOT NULL,
+				prev_hash BLOB NOT NULL,
+				this_hash BLOB NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS audit_ts_i ON audit (ts);
+		`)
+		return err
+	}},
+	{Version: 5, Description: "add signing_key and revoked_jti tables for JWT session tokens", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS signing_key (
+				id INTEGER PRIMARY KEY CHECK (id = 1),
+				kid TEXT NOT NULL,
+				priv BLOB NOT NULL
+			);
+			CREATE TABLE IF NOT EXISTS revoked_jti (
+				jti TEXT PRIMARY KEY,
+				expires DATETIME NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS revoked_jti_expires_i ON revoked_jti (expires);
+		`)
+		return err
+	}},
+	{Version: 6, Description: "add sessions table so active sessions survive a restart", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS session (
+				jti TEXT PRIMARY KEY,
+				key_name TEXT NOT NULL,
+				scope TEXT NOT NULL,
+				issued DATETIME NOT NULL,
+				expires DATETIME NOT NULL,
+				last_used DATETIME NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS session_expires_i ON session (expires);
+		`)
+		return err
+	}},
+}
+
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+type SQLiteStoreCtx struct {
+	_db *sql.DB
+	db  Queryable
+	ctx context.Context
+	tx  *sql.Tx // set if inside a transaction, otherwise nil
+}
+
+// The common read-only parts of sql.DB and sql.Tx interfaces, so we can pass either
+// one to some helper functions (for methods that appear on both SQLiteStore and
+// SQLiteStoreTransaction)
+type Queryable interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// New opens (and creates, if necessary) a SQLite-backed Store at `filename`.
+// Registered with internal/store as the "sqlite3" driver. This driver does
+// not support at-rest encryption; opts.Passphrase is ignored (see
+// store/sqlcipher for a passphrase-protected equivalent).
+func New(filename string, opts store.Options) (internal.Store, error) {
+	db, err := sql.Open("sqlite3", filename)
+	s := &SQLiteStore{db: db}
+	if err != nil {
+		return s, dbErr(err, "opening database")
+	}
+	// limit concurrent access until we figure out a way to start transactions
+	// with the BEGIN CONCURRENT statement in Go.
+	db.SetMaxOpenConns(1)
+	// init / upgrade tables and indexes
+	err = migrations.MigrateUp(db, Migrations)
+	if err != nil {
+		return s, dbErr(err, "migrating database schema")
+	}
+	return s, err
+}
+
+func (s *SQLiteStore) Close() {
+	s.db.Close()
+}
+
+func (s *SQLiteStore) WithCtx(ctx context.Context) internal.StoreCtx {
+	return &SQLiteStoreCtx{
+		_db: s.db,
+		db:  s.db,
+		ctx: ctx,
+	}
+}
+
+func IsConflict(err error) bool {
+	// this allows the work() function in doTxn() to return ErrDBConflict
+	// to cause the transaction to retry.
+	if errors.Is(err, internal.ErrDBConflict) {
+		return true
+	}
+	// these errors come from db.Begin(), db.Commit() or potentially any query.
+	if sqErr, isSq := err.(sqlite3.Error); isSq {
+		if sqErr.Code == sqlite3.ErrBusy || sqErr.Code == sqlite3.ErrLocked {
+			return true
+		}
+	}
+	return false
+}
+
+func IsConstraint(err error) bool {
+	if sqErr, isSq := err.(sqlite3.Error); isSq {
+		if sqErr.Code == sqlite3.ErrConstraint {
+			return true
+		}
+	}
+	return false
+}
+
+func (s SQLiteStoreCtx) doTxn(name string, work func(tx *sql.Tx) error) error {
+	db := s._db
+	if s.tx != nil {
+		// already running inside a user-level store.Transaction,
+		// so just run the work function directly.
+		return work(s.tx)
+	}
+	limit := 120
+	for {
+		tx, err := db.Begin()
+		if err != nil {
+			if IsConflict(err) {
+				s.Sleep(250 * time.Millisecond)
+				limit--
+				if limit != 0 {
+					continue
+				}
+			}
+			return fmt.Errorf("[Store] cannot begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+		// work() may return ErrDBConflict to retry the transaction.
+		// any sqlite conflict error will also retry the transaction.
+		err = work(tx)
+		if err != nil {
+			if IsConflict(err) {
+				s.Sleep(250 * time.Millisecond)
+				limit--
+				if limit != 0 {
+					continue
+				}
+			}
+			return err
+		}
+		err = tx.Commit()
+		if err != nil {
+			if IsConflict(err) {
+				s.Sleep(250 * time.Millisecond)
+				limit--
+				if limit != 0 {
+					continue
+				}
+			}
+			return fmt.Errorf("[Store] cannot commit %v: %w", name, err)
+		}
+		return nil
+	}
+}
+
+func (s SQLiteStoreCtx) Sleep(dur time.Duration) {
+	select {
+	case <-s.ctx.Done():
+	case <-time.After(dur):
+	}
+}
+
+// nullable maps an empty string to SQL NULL, so the `name UNIQUE` constraint
+// doesn't treat multiple unnamed keys as duplicates of each other.
+func nullable(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func dbErr(err error, where string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return internal.ErrNotFound
+	}
+	if sqErr, isSq := err.(sqlite3.Error); isSq {
+		if sqErr.Code == sqlite3.ErrConstraint {
+			// MUST detect 'AlreadyExists' to fulfil the API contract!
+			// Constraint violation, e.g. a duplicate key.
+			return internal.ErrAlreadyExists
+		}
+		if sqErr.Code == sqlite3.ErrBusy || sqErr.Code == sqlite3.ErrLocked {
+			// SQLite has a single-writer policy, even in WAL (write-ahead) mode.
+			// SQLite will return BUSY if the database is locked by another connection.
+			// We treat this as a transient database conflict, and the caller should retry.
+			return internal.ErrDBConflict
+		}
+	}
+	return fmt.Errorf("store: %v: %w", where, err)
+}
+
+// STORE INTERFACE
+
+func (s SQLiteStoreCtx) Transaction(work func(tx internal.StoreTxn) error) error {
+	return s.doTxn("txn", func(tx *sql.Tx) error {
+		stx := &SQLiteStoreCtx{
+			_db: s._db,
+			db:  tx,
+			ctx: s.ctx,
+			tx:  tx,
+		}
+		return work(stx)
+	})
+}
+
+func (s SQLiteStoreCtx) NextKeyID() (id int, err error) {
+	err = s.doTxn("NextKeyID", func(tx *sql.Tx) error {
+		row := tx.QueryRow("SELECT COALESCE(MAX(id),0)+1 FROM config")
+		err = row.Scan(&id)
+		if err != nil {
+			return dbErr(err, "NextKeyID")
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) SetKey(id int, name string, salt []byte, nonce []byte, enc []byte, pub []byte, kdf []byte, allowReplace bool) error {
+	return s.doTxn("SetKey", func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO config (id,name,salt,nonce,enc,pub,kdf_params) VALUES (?,?,?,?,?,?,?)", id, nullable(name), salt, nonce, enc, pub, kdf)
+		if err != nil {
+			if IsConstraint(err) && allowReplace {
+				// already exists and allowed to replace.
+				_, err = tx.Exec("UPDATE config SET name=?,salt=?,nonce=?,enc=?,pub=?,kdf_params=? WHERE id=?", nullable(name), salt, nonce, enc, pub, kdf, id)
+				if err != nil {
+					return dbErr(err, "SetKey")
+				}
+				return nil
+			}
+			return dbErr(err, "SetKey") // AlreadyExists or error
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) GetKey(id int) (name string, salt []byte, nonce []byte, enc []byte, pub []byte, kdf []byte, err error) {
+	err = s.doTxn("GetKey", func(tx *sql.Tx) error {
+		var nameCol sql.NullString
+		row := tx.QueryRow("SELECT name,salt,nonce,enc,pub,kdf_params FROM config WHERE id=?", id)
+		err = row.Scan(&nameCol, &salt, &nonce, &enc, &pub, &kdf)
+		if err != nil {
+			return dbErr(err, "GetKey")
+		}
+		name = nameCol.String
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) GetKeyByName(name string) (id int, salt []byte, nonce []byte, enc []byte, pub []byte, kdf []byte, err error) {
+	err = s.doTxn("GetKeyByName", func(tx *sql.Tx) error {
+		row := tx.QueryRow("SELECT id,salt,nonce,enc,pub,kdf_params FROM config WHERE name=?", name)
+		err = row.Scan(&id, &salt, &nonce, &enc, &pub, &kdf)
+		if err != nil {
+			return dbErr(err, "GetKeyByName")
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) GetKeyPub(id int) (pub []byte, err error) {
+	err = s.doTxn("GetKeyPub", func(tx *sql.Tx) error {
+		row := tx.QueryRow("SELECT pub FROM config WHERE id=?", id)
+		err = row.Scan(&pub)
+		if err != nil {
+			return dbErr(err, "GetKeyPub")
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) ListKeys() (names []string, err error) {
+	err = s.doTxn("ListKeys", func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT name FROM config WHERE name IS NOT NULL ORDER BY id")
+		if err != nil {
+			return dbErr(err, "ListKeys")
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return dbErr(err, "ListKeys")
+			}
+			names = append(names, name)
+		}
+		return rows.Err()
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) DeleteKey(id int) error {
+	return s.doTxn("DeleteKey", func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM config WHERE id=?", id)
+		if err != nil {
+			return dbErr(err, "DeleteKey")
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) SetDelegate(id string, parentKey int, salt, nonce, enc, pub, kdf []byte, keyid uint32, kind string, signer string, allowReplace bool) (err error) {
+	return s.doTxn("SetDelegate", func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO delegate (id,parent_key,salt,nonce,enc,pub,kdf_params,keyid,delegate_kind,signer) VALUES (?,?,?,?,?,?,?,?,?,?)", id, parentKey, salt, nonce, enc, pub, kdf, keyid, kind, signer)
+		if err != nil {
+			if IsConstraint(err) && allowReplace {
+				// already exists and allowed to replace; parent_key and keyid are unchanged.
+				_, err = tx.Exec("UPDATE delegate SET salt=?,nonce=?,enc=?,pub=?,kdf_params=? WHERE id=?", salt, nonce, enc, pub, kdf, id)
+				if err != nil {
+					return dbErr(err, "SetDelegate")
+				}
+				return nil
+			}
+			return dbErr(err, "SetDelegate") // AlreadyExists or error
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) GetDelegatePub(id string) (pub []byte, parentKey int, keyid uint32, kind string, signer string, err error) {
+	err = s.doTxn("GetDelegatePub", func(tx *sql.Tx) error {
+		row := tx.QueryRow("SELECT pub,parent_key,keyid,delegate_kind,signer FROM delegate WHERE id=?", id)
+		err = row.Scan(&pub, &parentKey, &keyid, &kind, &signer)
+		if err != nil {
+			return dbErr(err, "GetDelegatePub")
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) GetDelegatePriv(id string) (parentKey int, salt, nonce, enc, pub, kdf []byte, keyid uint32, kind string, signer string, err error) {
+	err = s.doTxn("GetDelegatePriv", func(tx *sql.Tx) error {
+		row := tx.QueryRow("SELECT parent_key,salt,nonce,enc,pub,kdf_params,keyid,delegate_kind,signer FROM delegate WHERE id=?", id)
+		err = row.Scan(&parentKey, &salt, &nonce, &enc, &pub, &kdf, &keyid, &kind, &signer)
+		if err != nil {
+			return dbErr(err, "GetDelegatePriv")
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) GetMaxDelegate(parentKey int) (max uint32, err error) {
+	err = s.doTxn("GetMaxDelegate", func(tx *sql.Tx) error {
+		row := tx.QueryRow("SELECT COALESCE(MAX(keyid),0) FROM delegate WHERE parent_key=?", parentKey)
+		err = row.Scan(&max)
+		if err != nil {
+			return dbErr(err, "GetMaxDelegate")
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) AppendAudit(ev audit.Event) error {
+	return s.doTxn("AppendAudit", func(tx *sql.Tx) error {
+		var prevHash []byte
+		row := tx.QueryRow("SELECT this_hash FROM audit ORDER BY id DESC LIMIT 1")
+		err := row.Scan(&prevHash)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return dbErr(err, "AppendAudit")
+		}
+		ts := time.Now().UTC()
+		thisHash := audit.ComputeHash(prevHash, ts, ev)
+		_, err = tx.Exec("INSERT INTO audit (ts,op,key_id,delegate_id,caller,outcome,prev_hash,this_hash) VALUES (?,?,?,?,?,?,?,?)",
+			ts, ev.Op, ev.KeyID, ev.DelegateID, ev.Caller, ev.Outcome, prevHash, thisHash)
+		if err != nil {
+			return dbErr(err, "AppendAudit")
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) StreamAudit(since time.Time) (records []audit.Record, err error) {
+	err = s.doTxn("StreamAudit", func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT id,ts,op,key_id,delegate_id,caller,outcome,prev_hash,this_hash FROM audit WHERE ts>=? ORDER BY id", since)
+		if err != nil {
+			return dbErr(err, "StreamAudit")
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var rec audit.Record
+			if err := rows.Scan(&rec.ID, &rec.Ts, &rec.Op, &rec.KeyID, &rec.DelegateID, &rec.Caller, &rec.Outcome, &rec.PrevHash, &rec.ThisHash); err != nil {
+				return dbErr(err, "StreamAudit")
+			}
+			records = append(records, rec)
+		}
+		return rows.Err()
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) VerifyAuditChain() (brokenID int64, err error) {
+	records, err := s.StreamAudit(time.Time{})
+	if err != nil {
+		return -1, err
+	}
+	return audit.Verify(records), nil
+}
+
+func (s SQLiteStoreCtx) GetOrCreateSigningKey() (priv ed25519.PrivateKey, kid string, err error) {
+	err = s.doTxn("GetOrCreateSigningKey", func(tx *sql.Tx) error {
+		row := tx.QueryRow("SELECT kid,priv FROM signing_key WHERE id=1")
+		terr := row.Scan(&kid, &priv)
+		if terr == nil {
+			return nil
+		}
+		if !errors.Is(terr, sql.ErrNoRows) {
+			return dbErr(terr, "GetOrCreateSigningKey")
+		}
+		pub, newpriv, terr := ed25519.GenerateKey(rand.Reader)
+		if terr != nil {
+			return terr
+		}
+		sum := sha256.Sum256(pub)
+		kid = hex.EncodeToString(sum[:8])
+		priv = newpriv
+		_, terr = tx.Exec("INSERT INTO signing_key (id,kid,priv) VALUES (1,?,?)", kid, []byte(priv))
+		if terr != nil {
+			return dbErr(terr, "GetOrCreateSigningKey")
+		}
+		return nil
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) RevokeJTI(jti string, expires time.Time) error {
+	return s.doTxn("RevokeJTI", func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT OR REPLACE INTO revoked_jti (jti,expires) VALUES (?,?)", jti, expires)
+		if err != nil {
+			return dbErr(err, "RevokeJTI")
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) IsJTIRevoked(jti string) (revoked bool, err error) {
+	err = s.doTxn("IsJTIRevoked", func(tx *sql.Tx) error {
+		row := tx.QueryRow("SELECT 1 FROM revoked_jti WHERE jti=?", jti)
+		terr := row.Scan(new(int))
+		if terr == nil {
+			revoked = true
+			return nil
+		}
+		if errors.Is(terr, sql.ErrNoRows) {
+			return nil
+		}
+		return dbErr(terr, "IsJTIRevoked")
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) PutSession(sess internal.Session) error {
+	scope, err := json.Marshal(sess.Scope)
+	if err != nil {
+		return err
+	}
+	return s.doTxn("PutSession", func(tx *sql.Tx) error {
+		_, terr := tx.Exec(`
+			INSERT OR REPLACE INTO session (jti,key_name,scope,issued,expires,last_used)
+			VALUES (?,?,?,?,?,?)
+		`, sess.Jti, sess.KeyName, string(scope), sess.Issued, sess.Expires, sess.LastUsed)
+		if terr != nil {
+			return dbErr(terr, "PutSession")
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) TouchSession(jti string, used time.Time) error {
+	return s.doTxn("TouchSession", func(tx *sql.Tx) error {
+		_, terr := tx.Exec("UPDATE session SET last_used=? WHERE jti=?", used, jti)
+		if terr != nil {
+			return dbErr(terr, "TouchSession")
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) DeleteSession(jti string) error {
+	return s.doTxn("DeleteSession", func(tx *sql.Tx) error {
+		_, terr := tx.Exec("DELETE FROM session WHERE jti=?", jti)
+		if terr != nil {
+			return dbErr(terr, "DeleteSession")
+		}
+		return nil
+	})
+}
+
+func (s SQLiteStoreCtx) ListSessions() (sessions []internal.Session, err error) {
+	err = s.doTxn("ListSessions", func(tx *sql.Tx) error {
+		rows, terr := tx.Query("SELECT jti,key_name,scope,issued,expires,last_used FROM session")
+		if terr != nil {
+			return dbErr(terr, "ListSessions")
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var sess internal.Session
+			var scope string
+			if terr := rows.Scan(&sess.Jti, &sess.KeyName, &scope, &sess.Issued, &sess.Expires, &sess.LastUsed); terr != nil {
+				return dbErr(terr, "ListSessions")
+			}
+			if terr := json.Unmarshal([]byte(scope), &sess.Scope); terr != nil {
+				return terr
+			}
+			sessions = append(sessions, sess)
+		}
+		return rows.Err()
+	})
+	return
+}
+
+func (s SQLiteStoreCtx) PruneSessions(now time.Time) error {
+	return s.doTxn("PruneSessions", func(tx *sql.Tx) error {
+		_, terr := tx.Exec("DELETE FROM session WHERE expires<?", now)
+		if terr != nil {
+			return dbErr(terr, "PruneSessions")
+		}
+		return nil
+	})
+}
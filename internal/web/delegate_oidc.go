@@ -0,0 +1,153 @@
+package web
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// delegateAttestTTL is how long an attestation token issued by
+// /delegate/authorize stays valid, short enough that a relying pup doesn't
+// need to worry about revocation: it just re-authorizes before it expires.
+const delegateAttestTTL = 5 * 60 // seconds
+
+// API: GET /delegate/authorize?id=pup.xyz&token=hex&nonce=...&aud=... (Authorization: Bearer <token>)
+//
+// Mints an OIDC-style attestation token for delegate `id`, signed by its own
+// key, so pup.xyz can prove "I am pup.xyz under this dogebox" to `aud`
+// without ever handling its own private key. See AttestDelegate for the
+// token's signature format.
+//
+// `token` is the delegate's own secret, as minted once by /create-delegate,
+// and is what actually decrypts the delegate's key -- it is never the
+// session JWT. The Authorization header (a session token with
+// ScopeDelegateRead, same as /get-delegate-key) only gates who may call
+// this endpoint at all; the two secrets are deliberately kept separate.
+//
+// Success: { token:"hdr.claims.sig" }
+// Failure: { error:"bad-request|unauthorized|not-found|wrong-token|error", "reason":"str" }
+func (a *WebAPI) delegateAuthorize(w http.ResponseWriter, r *http.Request) {
+	options := "GET, OPTIONS"
+	if r.Method == http.MethodGet {
+		if _, ok := bearerFromContext(r.Context()); !ok {
+			sendError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid Authorization header", options)
+			return
+		}
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'id' query parameter", options)
+			return
+		}
+		delegateToken := r.URL.Query().Get("token")
+		if delegateToken == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'token' query parameter", options)
+			return
+		}
+		pub, err := a.keymgr.GetDelegatePub(id)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+		now := time.Now().Unix()
+		claims := map[string]any{
+			"iss": fmt.Sprintf("dkm://%v", r.Host),
+			"sub": id,
+			"iat": now,
+			"exp": now + delegateAttestTTL,
+			"pub": fmt.Sprintf("%x", pub),
+		}
+		if aud := r.URL.Query().Get("aud"); aud != "" {
+			claims["aud"] = aud
+		}
+		if nonce := r.URL.Query().Get("nonce"); nonce != "" {
+			claims["nonce"] = nonce
+		}
+		token, err := a.keymgr.AttestDelegate(id, delegateToken, claims)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		res := DelegateAuthorizeResponse{Token: token}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
+	}
+}
+
+type DelegateAuthorizeResponse struct {
+	Token string `json:"token"`
+}
+
+// DelegateJWK is a JWK Set entry for a delegate's secp256k1 public key, in
+// the "EC"/"secp256k1" form described by RFC 7518 section 6.2.1 (the curve
+// name itself is not IANA-registered, but is the conventional choice used
+// by e.g. did:key and Ethereum JOSE libraries for this curve).
+type DelegateJWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+type DelegateJWKSet struct {
+	Keys []DelegateJWK `json:"keys"`
+}
+
+// API: GET /delegate/.well-known/jwks.json?id=pup.xyz
+//
+// Exposes delegate `id`'s public key in JWK form, so a relying pup can
+// verify an /delegate/authorize token offline, without calling back into DKM.
+//
+// Success: { keys:[{"kty":"EC","crv":"secp256k1","x":"...","y":"...","kid":"pup.xyz","use":"sig","alg":"ES256K"}] }
+// Failure: { error:"bad-request|not-found|error", "reason":"str" }
+func (a *WebAPI) delegateJWKS(w http.ResponseWriter, r *http.Request) {
+	options := "GET, OPTIONS"
+	if r.Method == http.MethodGet {
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'id' query parameter", options)
+			return
+		}
+		pub, err := a.keymgr.GetDelegatePub(id)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+		key, err := secp256k1.ParsePubKey(pub)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "error", fmt.Sprintf("parsing delegate pubkey: %v", err), options)
+			return
+		}
+
+		// response
+		res := DelegateJWKSet{Keys: []DelegateJWK{{
+			Kty: "EC",
+			Crv: "secp256k1",
+			X:   b64FieldElement(key.X()),
+			Y:   b64FieldElement(key.Y()),
+			Kid: id,
+			Use: "sig",
+			Alg: "ES256K",
+		}}}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
+	}
+}
+
+// b64FieldElement encodes a secp256k1 coordinate as the fixed-width, 32-byte
+// big-endian base64url string a JWK "x"/"y" member requires.
+func b64FieldElement(n *big.Int) string {
+	var buf [32]byte
+	n.FillBytes(buf[:])
+	return base64.RawURLEncoding.EncodeToString(buf[:])
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"code.dogecoin.org/dkm/internal"
+	"code.dogecoin.org/governor"
+)
+
+// InternalAPI hands out delegate keys to other pups on the same host over a
+// Unix socket, instead of the TCP bind address. Trust is established by
+// filesystem permissions on the socket (root-owned, mode 0600) rather than a
+// bearer token: every request runs as the single session token passed to
+// NewInternal, which main obtains via --unseal-from-env/--unseal-from-file at
+// startup.
+type InternalAPI struct {
+	governor.ServiceCtx
+	socketPath string
+	srv        http.Server
+}
+
+// NewInternal exposes /make-delegate on a Unix socket at socketPath.
+// Unlike /create-delegate, MakeDelegate needs no password: it reuses the
+// master key decrypted into memory when `token`'s session was created,
+// which is what makes it safe for unattended local callers.
+func NewInternal(socketPath string, keymgr internal.KeyMgr, token string) governor.Service {
+	mux := http.NewServeMux()
+	a := &WebAPI{keymgr: keymgr}
+	mux.HandleFunc("/make-delegate", a.makeDelegate(token))
+	return &InternalAPI{
+		socketPath: socketPath,
+		srv:        http.Server{Handler: mux},
+	}
+}
+
+// goroutine
+func (a *InternalAPI) Run() {
+	_ = os.Remove(a.socketPath) // ignore: socket may not already exist
+	listener, err := net.Listen("unix", a.socketPath)
+	if err != nil {
+		log.Printf("[dkm-internal] listen %v: %v", a.socketPath, err)
+		return
+	}
+	if err := os.Chmod(a.socketPath, 0600); err != nil {
+		log.Printf("[dkm-internal] chmod %v: %v", a.socketPath, err)
+	}
+	log.Printf("[dkm-internal] listening on: unix://%v", a.socketPath)
+	if err := a.srv.Serve(listener); err != http.ErrServerClosed { // blocking call
+		log.Printf("[dkm-internal] HTTP server: %v", err)
+	}
+}
+
+// called on any
+func (a *InternalAPI) Stop() {
+	// new goroutine because Shutdown() blocks
+	go func() {
+		a.srv.Shutdown(a.Context)
+		os.Remove(a.socketPath)
+	}()
+}
+
+type MakeDelegateRequest struct {
+	ID string `json:"id"`
+}
+type MakeDelegateResponse struct {
+	Priv string `json:"priv"`
+	Pub  string `json:"pub"`
+	Wif  string `json:"wif"`
+}
+
+// API (unix socket only): /make-delegate { id:"pup.xyz" }
+//
+// Success: { priv:"hex", pub:"hex", wif:"str" }
+// Failure: { error:"bad-request|external-delegate|error", "reason":"str" }
+func (a *WebAPI) makeDelegate(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		options := "POST, OPTIONS"
+		if r.Method == http.MethodPost {
+			// request
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
+				return
+			}
+			var args MakeDelegateRequest
+			err = json.Unmarshal(body, &args)
+			if err != nil {
+				sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
+				return
+			}
+			if args.ID == "" {
+				sendError(w, http.StatusInternalServerError, "bad-request", "missing 'id'", options)
+				return
+			}
+
+			priv, pub, wif, err := a.keymgr.MakeDelegate(r.Context(), args.ID, token)
+			if err != nil {
+				sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+				return
+			}
+
+			// response
+			res := MakeDelegateResponse{Priv: hex.EncodeToString(priv), Pub: hex.EncodeToString(pub), Wif: wif}
+			sendJson(w, res, options)
+		} else {
+			sendOptions(w, r, options)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"code.dogecoin.org/dkm/internal"
+	"code.dogecoin.org/dkm/internal/jwt"
 	"code.dogecoin.org/dkm/internal/keymgr"
 	"code.dogecoin.org/governor"
 	"github.com/dogeorg/doge"
@@ -40,13 +41,30 @@ func New(bind internal.Address, store internal.Store, keymgr internal.KeyMgr) go
 	}
 	mux.HandleFunc("/create", a.create)
 	mux.HandleFunc("/login", a.login)
-	mux.HandleFunc("/roll-token", a.rollToken)
-	mux.HandleFunc("/logout", a.logout)
-	mux.HandleFunc("/change-password", a.changePassword)
+	mux.HandleFunc("/roll-token", a.withBearerAuth("POST, OPTIONS", a.rollToken))
+	mux.HandleFunc("/logout", a.withBearerAuth("POST, OPTIONS", a.logout))
+	mux.HandleFunc("/change-password", a.withBearerAuth("POST, OPTIONS", a.changePassword))
 	mux.HandleFunc("/recover-password", a.recoverPassword)
-	mux.HandleFunc("/create-delegate", a.createDelegate)
-	mux.HandleFunc("/get-delegate-key", a.getDelegatePriv)
+	mux.HandleFunc("/create-delegate", a.withBearerAuth("POST, OPTIONS", a.createDelegate))
+	mux.HandleFunc("/get-delegate-key", a.withBearerAuth("POST, OPTIONS", a.getDelegatePriv))
 	mux.HandleFunc("/get-delegate-pub", a.getDelegatePub)
+	mux.HandleFunc("/create-named-key", a.createNamedKey)
+	mux.HandleFunc("/list-keys", a.listKeys)
+	mux.HandleFunc("/delete-key", a.deleteKey)
+	mux.HandleFunc("/login-to", a.loginTo)
+	mux.HandleFunc("/create-delegate-for", a.withBearerAuth("POST, OPTIONS", a.createDelegateFor))
+	mux.HandleFunc("/create-external-delegate", a.withBearerAuth("POST, OPTIONS", a.createExternalDelegate))
+	mux.HandleFunc("/create-external-delegate-for", a.withBearerAuth("POST, OPTIONS", a.createExternalDelegateFor))
+	mux.HandleFunc("/sign-with-delegate", a.signWithDelegate)
+	mux.HandleFunc("/sign-delegate", a.signDelegate)
+	mux.HandleFunc("/verify-delegate", a.verifyDelegate)
+	mux.HandleFunc("/stream-audit", a.streamAudit)
+	mux.HandleFunc("/verify-audit-chain", a.verifyAuditChain)
+	mux.HandleFunc("/jwks.json", a.jwks)
+	mux.HandleFunc("/verify-token", a.verifyToken)
+	mux.HandleFunc("/sessions", a.sessions)
+	mux.HandleFunc("/delegate/authorize", a.withBearerAuth("GET, OPTIONS", a.delegateAuthorize))
+	mux.HandleFunc("/delegate/.well-known/jwks.json", a.delegateJWKS)
 
 	return a
 }
@@ -122,6 +140,10 @@ func (a *WebAPI) create(w http.ResponseWriter, r *http.Request) {
 
 type LoginRequest struct {
 	Password string `json:"password"`
+	// Scope restricts the issued token's capabilities, e.g. ["delegate:read"]
+	// for a token that can only read delegate keys, not create them. Omit for
+	// the full default scope.
+	Scope []string `json:"scope,omitempty"`
 }
 type LoginResponse struct {
 	Token    string `json:"token"`
@@ -154,7 +176,7 @@ func (a *WebAPI) login(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		token, ends, err := a.keymgr.LogIn(pass)
+		token, ends, err := a.keymgr.LogIn(r.Context(), pass, args.Scope)
 		if err != nil {
 			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
 		}
@@ -175,28 +197,34 @@ type RollTokenResponse struct {
 	ValidFor int    `json:"valid_for"`
 }
 
-// API: /roll-token {"token":"652b2b63ca6273119b0deb1da807879e"}
+// API: POST /roll-token, with "Authorization: Bearer <token>"
+// API (deprecated): /roll-token {"token":"652b2b63ca6273119b0deb1da807879e"}
 // => {"token":"52eef94ed16ea8dd1412c982d91e7de4","valid_for":600}
-// => {"error":"token","reason":"invalid or expired token"}
+// => {"error":"unauthorized","reason":"invalid or expired token"}
 func (a *WebAPI) rollToken(w http.ResponseWriter, r *http.Request) {
 	options := "POST, OPTIONS"
 	if r.Method == http.MethodPost {
-		// request
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
-			return
-		}
-		var args RollTokenRequest
-		err = json.Unmarshal(body, &args)
-		if err != nil {
-			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
-			return
+		auth, ok := bearerFromContext(r.Context())
+		tok := auth.token
+		if !ok {
+			// deprecated fallback: read the token from the JSON body.
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
+				return
+			}
+			var args RollTokenRequest
+			err = json.Unmarshal(body, &args)
+			if err != nil {
+				sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
+				return
+			}
+			tok = args.Token
 		}
 
-		newtoken, ends, err := a.keymgr.RollToken(args.Token)
+		newtoken, ends, err := a.keymgr.RollToken(tok)
 		if err != nil {
-			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			respondTokenError(w, err, options)
 			return
 		}
 
@@ -214,25 +242,31 @@ type LogOutRequest struct {
 type LogOutResponse struct {
 }
 
-// API: /logout {"token":"39d5c614a1c1bf4e7d117d0287d6dc41"}
+// API: POST /logout, with "Authorization: Bearer <token>"
+// API (deprecated): /logout {"token":"39d5c614a1c1bf4e7d117d0287d6dc41"}
 // => {}
 func (a *WebAPI) logout(w http.ResponseWriter, r *http.Request) {
 	options := "POST, OPTIONS"
 	if r.Method == http.MethodPost {
-		// request
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
-			return
-		}
-		var args LogOutRequest
-		err = json.Unmarshal(body, &args)
-		if err != nil {
-			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
-			return
+		auth, ok := bearerFromContext(r.Context())
+		tok := auth.token
+		if !ok {
+			// deprecated fallback: read the token from the JSON body.
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
+				return
+			}
+			var args LogOutRequest
+			err = json.Unmarshal(body, &args)
+			if err != nil {
+				sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
+				return
+			}
+			tok = args.Token
 		}
 
-		a.keymgr.LogOut(args.Token)
+		a.keymgr.LogOut(r.Context(), tok)
 
 		// response
 		res := LogOutResponse{}
@@ -250,8 +284,9 @@ type ChangePassResponse struct {
 	Changed bool `json:"changed"`
 }
 
-// API: /change-password {"password":"xya","newpassword":"xyz"}
+// API: /change-password {"password":"xya","newpassword":"xyz"}, with "Authorization: Bearer <token>"
 // => {"changed":true}
+// => {"error":"unauthorized","reason":"missing or invalid Authorization header"}
 // => {"error":"password","reason":"incorrect password"}
 // => {"error":"password","reason":"password is empty"}
 // => {"error":"newpassword","reason":"new password is empty"}
@@ -259,6 +294,12 @@ type ChangePassResponse struct {
 func (a *WebAPI) changePassword(w http.ResponseWriter, r *http.Request) {
 	options := "POST, OPTIONS"
 	if r.Method == http.MethodPost {
+		if _, ok := bearerFromContext(r.Context()); !ok {
+			time.Sleep(authDelay)
+			sendError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid Authorization header", options)
+			return
+		}
+
 		// request
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
@@ -285,7 +326,7 @@ func (a *WebAPI) changePassword(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// change the password
-		err = a.keymgr.ChangePassword(pass, newpass)
+		err = a.keymgr.ChangePassword(r.Context(), pass, newpass)
 		if err != nil {
 			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
 			return
@@ -343,7 +384,7 @@ func (a *WebAPI) recoverPassword(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// attempt to change the password
-		err = a.keymgr.RecoverPassword(args.Seedphrase, newpass)
+		err = a.keymgr.RecoverPassword(r.Context(), args.Seedphrase, newpass)
 		if err != nil {
 			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
 			return
@@ -402,7 +443,7 @@ func (a *WebAPI) createDelegate(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		token, pub, err := a.keymgr.CreateDelegate(args.ID, args.Pass)
+		token, pub, err := a.keymgr.CreateDelegate(r.Context(), args.ID, args.Pass)
 		if err != nil {
 			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
 		}
@@ -424,18 +465,33 @@ type DelegateKeyResponse struct {
 	Pub  string `json:"pub"`
 }
 
-// API: /get-delegate-key { id:"pup.xyz", token:"hex" }
+// API: /get-delegate-key { id:"pup.xyz", token:"hex" }, with "Authorization: Bearer <token>"
+//
+// `token` is the delegate's own secret, as minted once by /create-delegate
+// and never a session JWT: the Authorization header (a session token with
+// ScopeDelegateRead) gates who may call this endpoint at all, while `token`
+// is the passphrase GetDelegatePriv actually decrypts the delegate's key
+// with. The two are deliberately separate secrets.
 //
 // Success: { priv:"hex", pub:"hex" }
-// Failure: { error:"bad-request|not-found|wrong-token|error", "reason":"str" }
+// Failure: { error:"bad-request|unauthorized|not-found|wrong-token|error", "reason":"str" }
 //
 // Errors:
 //
 //	not-found: no delegate key found for id
 //	wrong-token: wrong token for this key id
+//
+// Unlike roll-token/logout, this has no deprecated JSON-body session-token
+// fallback: a missing or invalid Authorization header is a hard 401.
 func (a *WebAPI) getDelegatePriv(w http.ResponseWriter, r *http.Request) {
 	options := "POST, OPTIONS"
 	if r.Method == http.MethodPost {
+		if _, ok := bearerFromContext(r.Context()); !ok {
+			time.Sleep(authDelay)
+			sendError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid Authorization header", options)
+			return
+		}
+
 		// request
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
@@ -457,7 +513,7 @@ func (a *WebAPI) getDelegatePriv(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		priv, pub, err := a.keymgr.GetDelegatePriv(args.ID, args.Token)
+		priv, pub, err := a.keymgr.GetDelegatePriv(r.Context(), args.ID, args.Token)
 		if err != nil {
 			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
 		}
@@ -524,65 +580,838 @@ func (a *WebAPI) getDelegatePub(w http.ResponseWriter, r *http.Request) {
 	sendJson(w, res, options)
 }
 
-// HELPERS
+type CreateNamedKeyRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+type CreateNamedKeyResponse struct {
+	Seedphrase []string `json:"seedphrase"`
+}
 
-func sendJson(w http.ResponseWriter, res any, options string) {
-	bytes, err := json.Marshal(res)
-	if err != nil {
-		sendError(w, http.StatusInternalServerError, "error", fmt.Sprintf("encoding JSON: %v", err), options)
-		return
+// API: /create-named-key {"name":"cold","password":"xyz"}
+// => {"seedphrase":["remain","nothing","vendor", (24 words) ]}
+// => {"error":"bad-request","reason":"missing 'name'"}
+// => {"error":"password","reason":"password is empty"}
+// => {"error":"exists","reason":"..."}
+func (a *WebAPI) createNamedKey(w http.ResponseWriter, r *http.Request) {
+	options := "POST, OPTIONS"
+	if r.Method == http.MethodPost {
+		// request
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
+			return
+		}
+		var args CreateNamedKeyRequest
+		err = json.Unmarshal(body, &args)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
+			return
+		}
+		if args.Name == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'name'", options)
+			return
+		}
+
+		// validate password
+		pass := strings.TrimSpace(args.Password)
+		if len(pass) < 1 {
+			sendError(w, http.StatusInternalServerError, "password", "password is empty", options)
+			return
+		}
+
+		// generate the new key
+		mnemonic, err := a.keymgr.CreateNamedKey(args.Name, pass)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		res := CreateNamedKeyResponse{Seedphrase: mnemonic}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
-	w.Header().Set("Allow", options)
-	w.Write(bytes)
 }
 
-type WebError struct {
-	Error  string `json:"error"`
-	Reason string `json:"reason"`
+type ListKeysResponse struct {
+	Names []string `json:"names"`
 }
 
-func sendError(w http.ResponseWriter, statusCode int, code string, reason string, options string) {
-	bytes, err := json.Marshal(WebError{Error: code, Reason: reason})
-	if err != nil {
-		bytes = []byte(fmt.Sprintf("{\"error\":\"json\",\"reason\":\"encoding JSON: %s\"}", err.Error()))
-		statusCode = http.StatusInternalServerError
+// API: /list-keys
+// => {"names":["main","cold"]}
+func (a *WebAPI) listKeys(w http.ResponseWriter, r *http.Request) {
+	options := "GET, OPTIONS"
+	if r.Method == http.MethodGet {
+		names, err := a.keymgr.ListKeys()
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		res := ListKeysResponse{Names: names}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
-	w.Header().Set("Allow", options)
-	w.WriteHeader(statusCode)
-	w.Write(bytes)
 }
 
-func sendOptions(w http.ResponseWriter, r *http.Request, options string) {
-	switch r.Method {
-	case http.MethodOptions:
-		w.Header().Set("Allow", options)
-		w.WriteHeader(http.StatusNoContent)
+type DeleteKeyRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+type DeleteKeyResponse struct {
+	Deleted bool `json:"deleted"`
+}
 
-	default:
-		w.Header().Set("Allow", options)
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+// API: /delete-key {"name":"cold","password":"xyz"}
+// => {"deleted":true}
+// => {"error":"not-found","reason":"..."}
+// => {"error":"password","reason":"incorrect password"}
+func (a *WebAPI) deleteKey(w http.ResponseWriter, r *http.Request) {
+	options := "POST, OPTIONS"
+	if r.Method == http.MethodPost {
+		// request
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
+			return
+		}
+		var args DeleteKeyRequest
+		err = json.Unmarshal(body, &args)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
+			return
+		}
+		if args.Name == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'name'", options)
+			return
+		}
+
+		err = a.keymgr.DeleteKey(args.Name, args.Password)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		res := DeleteKeyResponse{Deleted: true}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
 	}
 }
 
-func codeForErr(err error) string {
-	if errors.Is(err, bip39.ErrOutOfEntropy) || errors.Is(err, keymgr.ErrOutOfEntropy) {
-		return "entropy"
+type LoginToRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	// Scope restricts the issued token's capabilities; see LoginRequest.Scope.
+	Scope []string `json:"scope,omitempty"`
+}
+type LoginToResponse struct {
+	Token    string `json:"token"`
+	ValidFor int    `json:"valid_for"`
+}
+
+// API: /login-to {"name":"cold","password":"xyz"}
+// => {"token":"652b2b63ca6273119b0deb1da807879e","valid_for":600}
+// => {"error":"password","reason":"incorrect password"}
+func (a *WebAPI) loginTo(w http.ResponseWriter, r *http.Request) {
+	options := "POST, OPTIONS"
+	if r.Method == http.MethodPost {
+		// request
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
+			return
+		}
+		var args LoginToRequest
+		err = json.Unmarshal(body, &args)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
+			return
+		}
+		if args.Name == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'name'", options)
+			return
+		}
+
+		// validate password
+		pass := strings.TrimSpace(args.Password)
+		if len(pass) < 1 {
+			sendError(w, http.StatusInternalServerError, "password", "password is empty", options)
+			return
+		}
+
+		token, ends, err := a.keymgr.LogInTo(r.Context(), args.Name, pass, args.Scope)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		res := LoginToResponse{Token: token, ValidFor: ends}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
 	}
-	if errors.Is(err, bip39.ErrWrongWord) {
-		return "wordlist"
+}
+
+type CreateDelegateForRequest struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	Pass string `json:"password"`
+}
+type CreateDelegateForResponse struct {
+	Token string `json:"token"`
+	Pub   string `json:"pub"`
+}
+
+// API: /create-delegate-for { name:"cold", id:"pup.xyz", password:"dogebox-rulez" }
+//
+// Success: { token:"hex", pub:"hex" }
+// Failure: { error:"bad-request|entropy|exists|password|nokey|error", "reason":"str" }
+func (a *WebAPI) createDelegateFor(w http.ResponseWriter, r *http.Request) {
+	options := "POST, OPTIONS"
+	if r.Method == http.MethodPost {
+		// request
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
+			return
+		}
+		var args CreateDelegateForRequest
+		err = json.Unmarshal(body, &args)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
+			return
+		}
+		if args.Name == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'name'", options)
+			return
+		}
+		if args.ID == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'id'", options)
+			return
+		}
+		if args.Pass == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'password'", options)
+			return
+		}
+
+		token, pub, err := a.keymgr.CreateDelegateFor(r.Context(), args.Name, args.ID, args.Pass)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		res := CreateDelegateForResponse{Token: token, Pub: hex.EncodeToString(pub)}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
 	}
-	if errors.Is(err, bip39.ErrWrongChecksum) {
-		return "checksum"
+}
+
+type CreateExternalDelegateResponse struct {
+	Token string `json:"token"`
+}
+
+// API: /create-external-delegate { id:"pup.xyz", pub:"hex", signer:"unix:///run/hwwallet.sock" }
+//
+// Registers a delegate whose private key is never loaded into this process;
+// signing is forwarded to `signer` (see /sign-with-delegate).
+//
+// Success: { token:"hex" }
+// Failure: { error:"bad-request|exists|nokey|error", "reason":"str" }
+func (a *WebAPI) createExternalDelegate(w http.ResponseWriter, r *http.Request) {
+	options := "POST, OPTIONS"
+	if r.Method == http.MethodPost {
+		a.createExternalDelegateCommon(w, r, options, keymgr.MainKeyName)
+	} else {
+		sendOptions(w, r, options)
 	}
-	if errors.Is(err, bip39.ErrWrongLength) {
-		return "length"
+}
+
+type CreateExternalDelegateForRequest struct {
+	Name   string `json:"name"`
+	ID     string `json:"id"`
+	Pub    string `json:"pub"`
+	Signer string `json:"signer"`
+}
+
+// API: /create-external-delegate-for { name:"cold", id:"pup.xyz", pub:"hex", signer:"unix:///run/hwwallet.sock" }
+//
+// CreateExternalDelegate against a named key rather than the default key.
+//
+// Success: { token:"hex" }
+// Failure: { error:"bad-request|exists|nokey|error", "reason":"str" }
+func (a *WebAPI) createExternalDelegateFor(w http.ResponseWriter, r *http.Request) {
+	options := "POST, OPTIONS"
+	if r.Method == http.MethodPost {
+		a.createExternalDelegateCommon(w, r, options, "")
+	} else {
+		sendOptions(w, r, options)
 	}
-	if errors.Is(err, keymgr.ErrBadToken) {
-		return "token"
+}
+
+// createExternalDelegateCommon backs both /create-external-delegate and
+// /create-external-delegate-for; pass a non-empty `forceName` to pin the
+// named key (the plain /create-external-delegate endpoint against "main").
+func (a *WebAPI) createExternalDelegateCommon(w http.ResponseWriter, r *http.Request, options string, forceName string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
+		return
+	}
+	var args CreateExternalDelegateForRequest
+	err = json.Unmarshal(body, &args)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
+		return
+	}
+	name := args.Name
+	if forceName != "" {
+		name = forceName
+	}
+	if name == "" {
+		sendError(w, http.StatusInternalServerError, "bad-request", "missing 'name'", options)
+		return
+	}
+	if args.ID == "" {
+		sendError(w, http.StatusInternalServerError, "bad-request", "missing 'id'", options)
+		return
+	}
+	if args.Pub == "" {
+		sendError(w, http.StatusInternalServerError, "bad-request", "missing 'pub'", options)
+		return
+	}
+	if args.Signer == "" {
+		sendError(w, http.StatusInternalServerError, "bad-request", "missing 'signer'", options)
+		return
+	}
+	pub, err := hex.DecodeString(args.Pub)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding 'pub': %v", err), options)
+		return
+	}
+
+	token, err := a.keymgr.CreateExternalDelegateFor(r.Context(), name, args.ID, pub, args.Signer)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+		return
+	}
+
+	// response
+	res := CreateExternalDelegateResponse{Token: token}
+	sendJson(w, res, options)
+}
+
+type SignWithDelegateRequest struct {
+	ID      string `json:"id"`
+	Token   string `json:"token"`
+	SigHash string `json:"sighash"`
+}
+type SignWithDelegateResponse struct {
+	Signature string `json:"signature"`
+}
+
+// API: /sign-with-delegate { id:"pup.xyz", token:"hex", sighash:"hex" }
+//
+// Forwards a signing request to the external signer registered for this
+// delegate (see /create-external-delegate); the private key never enters
+// this process.
+//
+// Success: { signature:"hex" }
+// Failure: { error:"bad-request|not-found|wrong-token|error", "reason":"str" }
+func (a *WebAPI) signWithDelegate(w http.ResponseWriter, r *http.Request) {
+	options := "POST, OPTIONS"
+	if r.Method == http.MethodPost {
+		// request
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
+			return
+		}
+		var args SignWithDelegateRequest
+		err = json.Unmarshal(body, &args)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
+			return
+		}
+		if args.ID == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'id'", options)
+			return
+		}
+		if args.Token == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'token'", options)
+			return
+		}
+		if args.SigHash == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'sighash'", options)
+			return
+		}
+		sighash, err := hex.DecodeString(args.SigHash)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding 'sighash': %v", err), options)
+			return
+		}
+
+		sig, err := a.keymgr.SignWithDelegate(args.ID, args.Token, sighash)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		res := SignWithDelegateResponse{Signature: hex.EncodeToString(sig)}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
+	}
+}
+
+type SignDelegateRequest struct {
+	ID      string `json:"id"`
+	Token   string `json:"token"`
+	Payload string `json:"payload"`
+	Hash    string `json:"hash"`
+}
+type SignDelegateResponse struct {
+	Signature string `json:"signature"`
+	Pub       string `json:"pub"`
+}
+
+// API: /sign-delegate { id:"pup.xyz", token:"hex", payload:"hex", hash:"sha256|dogecoin-message" }
+//
+// Signs an arbitrary payload with delegate key `id` (local or external)
+// without the private key ever leaving DKM, e.g. for ACME key-authorization
+// challenges or RPC request signing. `hash` defaults to "sha256" if omitted.
+//
+// Success: { signature:"hex", pub:"hex" }
+// Failure: { error:"bad-request|not-found|wrong-token|error", "reason":"str" }
+func (a *WebAPI) signDelegate(w http.ResponseWriter, r *http.Request) {
+	options := "POST, OPTIONS"
+	if r.Method == http.MethodPost {
+		// request
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
+			return
+		}
+		var args SignDelegateRequest
+		err = json.Unmarshal(body, &args)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
+			return
+		}
+		if args.ID == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'id'", options)
+			return
+		}
+		if args.Token == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'token'", options)
+			return
+		}
+		payload, err := hex.DecodeString(args.Payload)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding 'payload': %v", err), options)
+			return
+		}
+
+		sig, pub, err := a.keymgr.SignDelegate(args.ID, args.Token, payload, args.Hash)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		res := SignDelegateResponse{Signature: hex.EncodeToString(sig), Pub: hex.EncodeToString(pub)}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
+	}
+}
+
+type VerifyDelegateRequest struct {
+	ID        string `json:"id"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+	Hash      string `json:"hash"`
+}
+type VerifyDelegateResponse struct {
+	Verified bool `json:"verified"`
+}
+
+// API: /verify-delegate { id:"pup.xyz", payload:"hex", signature:"hex", hash:"sha256|dogecoin-message" }
+//
+// Checks `signature` (as returned by /sign-delegate) against `payload` and
+// delegate `id`'s recorded public key.
+//
+// Success: { verified:true }
+// Failure: { error:"bad-request|not-found|error", "reason":"str" }
+func (a *WebAPI) verifyDelegate(w http.ResponseWriter, r *http.Request) {
+	options := "POST, OPTIONS"
+	if r.Method == http.MethodPost {
+		// request
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
+			return
+		}
+		var args VerifyDelegateRequest
+		err = json.Unmarshal(body, &args)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
+			return
+		}
+		if args.ID == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'id'", options)
+			return
+		}
+		payload, err := hex.DecodeString(args.Payload)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding 'payload': %v", err), options)
+			return
+		}
+		sig, err := hex.DecodeString(args.Signature)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding 'signature': %v", err), options)
+			return
+		}
+
+		ok, err := a.keymgr.VerifyDelegate(args.ID, payload, sig, args.Hash)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		res := VerifyDelegateResponse{Verified: ok}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
+	}
+}
+
+type AuditRecord struct {
+	ID         int64  `json:"id"`
+	Ts         string `json:"ts"`
+	Op         string `json:"op"`
+	KeyID      int    `json:"key_id"`
+	DelegateID string `json:"delegate_id"`
+	Caller     string `json:"caller"`
+	Outcome    string `json:"outcome"`
+	PrevHash   string `json:"prev_hash"`
+	ThisHash   string `json:"this_hash"`
+}
+type StreamAuditResponse struct {
+	Records []AuditRecord `json:"records"`
+}
+
+// API: /stream-audit?since=2024-01-01T00:00:00Z
+// => {"records":[{"id":1,"ts":"...","op":"login","key_id":0,"delegate_id":"","caller":"","outcome":"ok","prev_hash":"","this_hash":"hex"}]}
+// => {"error":"bad-request","reason":"..."}
+func (a *WebAPI) streamAudit(w http.ResponseWriter, r *http.Request) {
+	options := "GET, OPTIONS"
+	if r.Method == http.MethodGet {
+		since := time.Time{}
+		if s := r.URL.Query().Get("since"); s != "" {
+			parsed, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("parsing 'since': %v", err), options)
+				return
+			}
+			since = parsed
+		}
+
+		records, err := a.cstore.StreamAudit(since)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		res := StreamAuditResponse{Records: make([]AuditRecord, len(records))}
+		for i, rec := range records {
+			res.Records[i] = AuditRecord{
+				ID:         rec.ID,
+				Ts:         rec.Ts.UTC().Format(time.RFC3339),
+				Op:         rec.Op,
+				KeyID:      rec.KeyID,
+				DelegateID: rec.DelegateID,
+				Caller:     rec.Caller,
+				Outcome:    rec.Outcome,
+				PrevHash:   hex.EncodeToString(rec.PrevHash),
+				ThisHash:   hex.EncodeToString(rec.ThisHash),
+			}
+		}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
+	}
+}
+
+type VerifyAuditChainResponse struct {
+	Ok       bool  `json:"ok"`
+	BrokenID int64 `json:"broken_id"`
+}
+
+// API: /verify-audit-chain
+// => {"ok":true,"broken_id":-1}
+// => {"ok":false,"broken_id":42}
+func (a *WebAPI) verifyAuditChain(w http.ResponseWriter, r *http.Request) {
+	options := "GET, OPTIONS"
+	if r.Method == http.MethodGet {
+		brokenID, err := a.cstore.VerifyAuditChain()
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		res := VerifyAuditChainResponse{Ok: brokenID < 0, BrokenID: brokenID}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
+	}
+}
+
+// API: GET /jwks.json
+// => {"keys":[{"kty":"OKP","crv":"Ed25519","x":"hex","kid":"...","use":"sig","alg":"EdDSA"}]}
+func (a *WebAPI) jwks(w http.ResponseWriter, r *http.Request) {
+	options := "GET, OPTIONS"
+	if r.Method == http.MethodGet {
+		pub, kid, err := a.keymgr.SigningPublicKey()
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		sendJson(w, jwt.JWKSFor(pub, kid), options)
+	} else {
+		sendOptions(w, r, options)
+	}
+}
+
+type VerifyTokenRequest struct {
+	Token string `json:"token"`
+}
+type VerifyTokenResponse struct {
+	Sub   string   `json:"sub"`
+	Exp   int64    `json:"exp"`
+	Jti   string   `json:"jti"`
+	Scope []string `json:"scope,omitempty"`
+}
+
+// API: /verify-token {"token":"xyz.xyz.xyz"}
+// => {"sub":"main","exp":1700000000,"jti":"hex","scope":["delegate:read"]}
+// => {"error":"token","reason":"invalid or expired token"}
+func (a *WebAPI) verifyToken(w http.ResponseWriter, r *http.Request) {
+	options := "POST, OPTIONS"
+	if r.Method == http.MethodPost {
+		// request
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "bad-request", fmt.Sprintf("bad request: %v", err), options)
+			return
+		}
+		var args VerifyTokenRequest
+		err = json.Unmarshal(body, &args)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "bad-request", fmt.Sprintf("decoding JSON: %v", err), options)
+			return
+		}
+		if args.Token == "" {
+			sendError(w, http.StatusInternalServerError, "bad-request", "missing 'token'", options)
+			return
+		}
+
+		claims, err := a.keymgr.VerifyToken(args.Token)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		res := VerifyTokenResponse{Sub: claims.Sub, Exp: claims.Exp, Jti: claims.Jti, Scope: claims.Scope}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
+	}
+}
+
+type SessionInfo struct {
+	Jti      string   `json:"jti"`
+	KeyName  string   `json:"key_name"`
+	Scope    []string `json:"scope,omitempty"`
+	Issued   int64    `json:"issued"`
+	Expires  int64    `json:"expires"`
+	LastUsed int64    `json:"last_used"`
+}
+type SessionsResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+// API: GET /sessions
+// => {"sessions":[{"jti":"hex","key_name":"main","scope":["delegate:read"],"issued":1700000000,"expires":1700000600,"last_used":1700000000}]}
+func (a *WebAPI) sessions(w http.ResponseWriter, r *http.Request) {
+	options := "GET, OPTIONS"
+	if r.Method == http.MethodGet {
+		all, err := a.keymgr.ListSessions()
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+			return
+		}
+
+		// response
+		infos := make([]SessionInfo, len(all))
+		for i, s := range all {
+			infos[i] = SessionInfo{Jti: s.Jti, KeyName: s.KeyName, Scope: s.Scope, Issued: s.Issued.Unix(), Expires: s.Expires.Unix(), LastUsed: s.LastUsed.Unix()}
+		}
+		res := SessionsResponse{Sessions: infos}
+		sendJson(w, res, options)
+	} else {
+		sendOptions(w, r, options)
+	}
+}
+
+// authDelay is added before responding to a request bearing a missing or
+// invalid bearer token, so that the response time doesn't itself reveal
+// whether a token was malformed, expired, revoked, or simply absent.
+const authDelay = 50 * time.Millisecond
+
+// bearerAuth is the result of validating a request's Authorization header,
+// carried on the request context by withBearerAuth.
+type bearerAuth struct {
+	token  string
+	claims jwt.Claims
+}
+
+type bearerKey struct{}
+
+func contextWithBearer(ctx context.Context, auth bearerAuth) context.Context {
+	ctx = context.WithValue(ctx, bearerKey{}, auth)
+	return jwt.WithScope(ctx, auth.claims.Scope)
+}
+
+// bearerFromContext returns the bearerAuth injected by withBearerAuth, if any.
+func bearerFromContext(ctx context.Context) (bearerAuth, bool) {
+	auth, ok := ctx.Value(bearerKey{}).(bearerAuth)
+	return auth, ok
+}
+
+// withBearerAuth wraps `next`, parsing an optional "Authorization: Bearer
+// <token>" header. If present, the token must be valid: an invalid or
+// malformed one is rejected with 401 (after authDelay, to blunt a timing
+// oracle) before `next` is ever called. If valid, its claims are made
+// available to `next` via bearerFromContext, and its scope via
+// jwt.ScopeFromContext.
+//
+// If the header is absent entirely, the request passes through unchanged:
+// `next` falls back to whatever token it finds in its own JSON body
+// (deprecated, kept for one release so existing callers keep working).
+func (a *WebAPI) withBearerAuth(options string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if r.Method == http.MethodOptions || auth == "" {
+			next(w, r)
+			return
+		}
+		const prefix = "Bearer "
+		if !strings.HasPrefix(auth, prefix) {
+			time.Sleep(authDelay)
+			sendError(w, http.StatusUnauthorized, "unauthorized", "malformed Authorization header", options)
+			return
+		}
+		token := strings.TrimPrefix(auth, prefix)
+		claims, err := a.keymgr.VerifyToken(token)
+		if err != nil {
+			time.Sleep(authDelay)
+			sendError(w, http.StatusUnauthorized, "unauthorized", "invalid or expired token", options)
+			return
+		}
+		next(w, r.WithContext(contextWithBearer(r.Context(), bearerAuth{token: token, claims: claims})))
+	}
+}
+
+// respondTokenError sends a 401 (after authDelay) for a keymgr token
+// validation failure, or falls back to the usual codeForErr/500 mapping for
+// anything else.
+func respondTokenError(w http.ResponseWriter, err error, options string) {
+	if errors.Is(err, keymgr.ErrBadToken) || errors.Is(err, jwt.ErrMalformed) || errors.Is(err, jwt.ErrBadSignature) || errors.Is(err, jwt.ErrExpired) {
+		time.Sleep(authDelay)
+		sendError(w, http.StatusUnauthorized, "unauthorized", "invalid or expired token", options)
+		return
+	}
+	sendError(w, http.StatusInternalServerError, codeForErr(err), err.Error(), options)
+}
+
+// HELPERS
+
+func sendJson(w http.ResponseWriter, res any, options string) {
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "error", fmt.Sprintf("encoding JSON: %v", err), options)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Allow", options)
+	w.Write(bytes)
+}
+
+type WebError struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+func sendError(w http.ResponseWriter, statusCode int, code string, reason string, options string) {
+	bytes, err := json.Marshal(WebError{Error: code, Reason: reason})
+	if err != nil {
+		bytes = []byte(fmt.Sprintf("{\"error\":\"json\",\"reason\":\"encoding JSON: %s\"}", err.Error()))
+		statusCode = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(bytes)))
+	w.Header().Set("Allow", options)
+	w.WriteHeader(statusCode)
+	w.Write(bytes)
+}
+
+func sendOptions(w http.ResponseWriter, r *http.Request, options string) {
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Allow", options)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", options)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func codeForErr(err error) string {
+	if errors.Is(err, bip39.ErrOutOfEntropy) || errors.Is(err, keymgr.ErrOutOfEntropy) {
+		return "entropy"
+	}
+	if errors.Is(err, bip39.ErrWrongWord) {
+		return "wordlist"
+	}
+	if errors.Is(err, bip39.ErrWrongChecksum) {
+		return "checksum"
+	}
+	if errors.Is(err, bip39.ErrWrongLength) {
+		return "length"
+	}
+	if errors.Is(err, keymgr.ErrBadToken) || errors.Is(err, jwt.ErrMalformed) || errors.Is(err, jwt.ErrBadSignature) || errors.Is(err, jwt.ErrExpired) {
+		return "token"
+	}
+	if errors.Is(err, keymgr.ErrForbiddenScope) {
+		return "forbidden"
 	}
 	if errors.Is(err, keymgr.ErrWrongPassword) {
 		return "password"